@@ -0,0 +1,48 @@
+package form
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// Compression selects how Encoder.EncodeTo compresses the form data it
+// writes, and how Decoder.DecodeFrom decompresses what it reads.
+type Compression int
+
+const (
+	NoCompression Compression = iota // plain form data
+	Gzip                             // gzip, as in Content-Encoding: gzip
+	Deflate                          // zlib, as in Content-Encoding: deflate
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// writer returns a writer that compresses to w.  It must be closed to
+// flush the compressed data.
+func (c Compression) writer(w io.Writer) io.WriteCloser {
+	switch c {
+	case Gzip:
+		return gzip.NewWriter(w)
+	case Deflate:
+		return zlib.NewWriter(w)
+	}
+	return nopWriteCloser{w}
+}
+
+// reader returns a reader that decompresses r.
+func (c Compression) reader(r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Deflate:
+		return zlib.NewReader(r)
+	}
+	return io.NopCloser(r), nil
+}
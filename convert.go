@@ -0,0 +1,104 @@
+package form
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"sync"
+)
+
+// converter holds the functions registered for a type by
+// RegisterConverter.
+type converter struct {
+	marshal   func(reflect.Value) (string, error)
+	unmarshal func(string, reflect.Value) error
+}
+
+var converters sync.Map
+
+// url.URL and net.IPNet only have String methods on their pointer types,
+// and no way to parse text in place, so they need converters to be
+// treated as single values rather than nested structs.
+func init() {
+	RegisterConverter(reflect.TypeOf(url.URL{}), func(v reflect.Value) (string, error) {
+		u := v.Interface().(url.URL)
+		return u.String(), nil
+	}, func(s string, v reflect.Value) error {
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	})
+	RegisterConverter(reflect.TypeOf(net.IPNet{}), func(v reflect.Value) (string, error) {
+		n := v.Interface().(net.IPNet)
+		return n.String(), nil
+	}, func(s string, v reflect.Value) error {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*n))
+		return nil
+	})
+	registerBigConverters()
+}
+
+// big.Int and big.Float have text methods, but those accept other bases
+// and, for big.Float, parse with only 64 bits of precision.  These
+// converters use base 10, and give a big.Float that has no precision set
+// enough bits for every digit of the input.
+func registerBigConverters() {
+	RegisterConverter(reflect.TypeOf(big.Int{}), func(v reflect.Value) (string, error) {
+		x := v.Interface().(big.Int)
+		return x.Text(10), nil
+	}, func(s string, v reflect.Value) error {
+		x := v.Addr().Interface().(*big.Int)
+		if _, ok := x.SetString(s, 10); !ok {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		return nil
+	})
+	RegisterConverter(reflect.TypeOf(big.Float{}), func(v reflect.Value) (string, error) {
+		x := v.Interface().(big.Float)
+		return x.Text('g', -1), nil
+	}, func(s string, v reflect.Value) error {
+		x := v.Addr().Interface().(*big.Float)
+		if x.Prec() == 0 {
+			prec := uint(len(s)) * 4
+			if prec < 64 {
+				prec = 64
+			}
+			x.SetPrec(prec)
+		}
+		if _, ok := x.SetString(s); !ok {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		return nil
+	})
+}
+
+// RegisterConverter teaches the package how to convert values of type t,
+// for types that don't implement encoding.TextMarshaler and
+// encoding.TextUnmarshaler themselves.  marshal is called with a value of
+// type t, and unmarshal with a settable value of type t.  Either may be
+// nil to keep the default behavior in that direction.  Registered
+// converters take precedence over all other handling of t, and
+// registering t again replaces the previous converter.
+//
+// RegisterConverter is typically called from an init function.  It is
+// safe to call concurrently with encoding and decoding.
+func RegisterConverter(t reflect.Type, marshal func(reflect.Value) (string, error), unmarshal func(string, reflect.Value) error) {
+	converters.Store(t, &converter{marshal: marshal, unmarshal: unmarshal})
+}
+
+func lookupConverter(t reflect.Type) (*converter, bool) {
+	c, ok := converters.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return c.(*converter), true
+}
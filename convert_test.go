@@ -0,0 +1,148 @@
+package form
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUUID [4]byte
+
+type point struct {
+	X, Y int
+}
+
+type converterStruct struct {
+	ID    fakeUUID   `json:"id"`
+	IDs   []fakeUUID `json:"ids"`
+	Ptr   *fakeUUID  `json:"ptr"`
+	Point point      `json:"point"`
+}
+
+func init() {
+	RegisterConverter(reflect.TypeOf(fakeUUID{}), func(v reflect.Value) (string, error) {
+		id := v.Interface().(fakeUUID)
+		return hex.EncodeToString(id[:]), nil
+	}, func(s string, v reflect.Value) error {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		if len(b) != 4 {
+			return errors.New("wrong length")
+		}
+		var id fakeUUID
+		copy(id[:], b)
+		v.Set(reflect.ValueOf(id))
+		return nil
+	})
+	RegisterConverter(reflect.TypeOf(point{}), func(v reflect.Value) (string, error) {
+		p := v.Interface().(point)
+		if p.X < 0 {
+			return "", errors.New("negative")
+		}
+		return string(rune('a'+p.X)) + string(rune('a'+p.Y)), nil
+	}, nil)
+}
+
+func TestRegisterConverter(t *testing.T) {
+	id := fakeUUID{0xde, 0xad, 0xbe, 0xef}
+	x := &converterStruct{
+		ID:    id,
+		IDs:   []fakeUUID{{1, 2, 3, 4}, {5, 6, 7, 8}},
+		Ptr:   &id,
+		Point: point{1, 2},
+	}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "id=deadbeef&ids=01020304&ids=05060708&ptr=deadbeef&point=bc", string(data))
+
+	y := &converterStruct{}
+	err = UnmarshalForm([]byte("id=deadbeef&ids=01020304&ids=05060708&ptr=deadbeef"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, id, y.ID)
+	assert.Equal(t, x.IDs, y.IDs)
+	assert.Equal(t, &id, y.Ptr)
+
+	err = UnmarshalForm([]byte("id=dead"), y)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "id", fe.Key)
+
+	x.Point.X = -1
+	_, err = MarshalForm(x)
+	assert.NotNil(t, err)
+}
+
+type netStruct struct {
+	Callback url.URL    `json:"callback"`
+	Homepage *url.URL   `json:"homepage"`
+	IP       net.IP     `json:"ip"`
+	Network  *net.IPNet `json:"network"`
+}
+
+func TestNetTypes(t *testing.T) {
+	data := []byte("callback=https%3A%2F%2Fexample.com%2Fcb%3Fx%3D1&homepage=http%3A%2F%2Fexample.org&ip=10.0.0.1&network=192.168.1.0%2F24")
+	x := &netStruct{}
+	err := UnmarshalForm(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com", x.Callback.Host)
+	assert.Equal(t, "x=1", x.Callback.RawQuery)
+	assert.Equal(t, "example.org", x.Homepage.Host)
+	assert.Equal(t, "10.0.0.1", x.IP.String())
+	assert.Equal(t, "192.168.1.0/24", x.Network.String())
+
+	out, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), string(out))
+
+	err = UnmarshalForm([]byte("network=10.0.0.1"), x)
+	assert.NotNil(t, err)
+	err = UnmarshalForm([]byte("ip=bogus"), x)
+	assert.NotNil(t, err)
+	err = UnmarshalForm([]byte("callback=%3A"), x)
+	assert.NotNil(t, err)
+}
+
+type bigStruct struct {
+	Int      big.Int    `form:"int"`
+	IntPtr   *big.Int   `form:"int_ptr"`
+	Float    big.Float  `form:"float"`
+	FloatPtr *big.Float `form:"float_ptr"`
+}
+
+func TestBigTypes(t *testing.T) {
+	const digits = "123456789012345678901234567890"
+	const pi = "3.14159265358979323846264338327950288"
+	x := bigStruct{}
+	x.Int.SetString(digits, 10)
+	x.IntPtr, _ = new(big.Int).SetString("-"+digits, 10)
+	x.Float.SetPrec(200).SetString(pi)
+	x.FloatPtr, _ = new(big.Float).SetPrec(200).SetString("-" + pi)
+
+	expect := "int=" + digits + "&int_ptr=-" + digits + "&float=" + pi + "&float_ptr=-" + pi
+	for _, obj := range []interface{}{x, &x} {
+		data, err := MarshalForm(obj)
+		assert.Nil(t, err)
+		assert.Equal(t, expect, string(data))
+	}
+
+	y := &bigStruct{}
+	err := UnmarshalForm([]byte(expect), y)
+	assert.Nil(t, err)
+	assert.Equal(t, digits, y.Int.String())
+	assert.Equal(t, "-"+digits, y.IntPtr.String())
+	assert.Equal(t, pi, y.Float.Text('g', -1)[:len(pi)])
+	assert.Equal(t, "-"+pi, y.FloatPtr.Text('g', -1)[:len(pi)+1])
+
+	err = UnmarshalForm([]byte("int=0x1f"), y)
+	assert.NotNil(t, err)
+	err = UnmarshalForm([]byte("float=pi"), y)
+	assert.NotNil(t, err)
+}
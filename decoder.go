@@ -0,0 +1,1011 @@
+package form
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decoder unmarshals form data into values.  The zero value behaves the
+// same as UnmarshalForm.
+type Decoder struct {
+	// TimeLayouts, if set, replaces the default list of layouts tried
+	// when parsing times.
+	TimeLayouts []string
+
+	// Location is the time zone used for times without an offset, such
+	// as naked dates.  The default is UTC.
+	Location *time.Location
+
+	// UnixTime parses time.Time values as Unix timestamps instead of
+	// with TimeLayouts.  The unix and unixmilli tag options set it for a
+	// single field.
+	UnixTime UnixTime
+
+	// TagName, if set, is the struct tag that field names and options
+	// are read from instead of the form and json tags.
+	TagName string
+
+	// UseSetters decodes keys for unexported fields by calling a setter
+	// method named Set followed by the field's name, like SetName for a
+	// field named name.  The method must have a pointer receiver and the
+	// signature func(string) error.  By default unexported fields are
+	// skipped.
+	UseSetters bool
+
+	// RawBytes copies values into []byte fields as-is instead of base64
+	// decoding them.
+	RawBytes bool
+
+	// MultiString controls how repeated keys are stored in a string
+	// field.  The default, JoinValues, joins them with Separator.
+	MultiString MultiString
+
+	// Separator is used to join repeated values into a string field.  The
+	// default is a comma.
+	Separator rune
+
+	// DisallowUnknownFields causes Decode to return an
+	// *UnknownFieldsError when the input contains keys that don't match
+	// any field of the destination struct.  It also makes it an error for
+	// an interface-valued map to have both a flat key like a and
+	// bracketed keys like a[b], as with DisallowDuplicateMapKeys.
+	DisallowUnknownFields bool
+
+	// CaseInsensitive matches keys to struct fields regardless of case.
+	// Decoding into a struct with fields that can't be told apart this way
+	// returns an error.
+	CaseInsensitive bool
+
+	// DisallowDuplicateMapKeys causes Decode to return an error when a
+	// key is repeated while decoding into a string-valued map, or when an
+	// interface-valued map has both a flat key like a and bracketed keys
+	// like a[b].  By default the last value wins, and flat keys win over
+	// bracketed ones.
+	DisallowDuplicateMapKeys bool
+
+	// DisallowDuplicateKeys causes Decode to return an error when a
+	// struct field that holds a single value is given more than one
+	// value, from a repeated key or from aliases of its key.  Slice
+	// and array fields, and fields with the rest option, still accept
+	// repeated keys.  By default the last value wins.
+	DisallowDuplicateKeys bool
+
+	// DisallowArrayOverflow causes Decode to return an error when there
+	// are more values than fit in an array field.  By default the extra
+	// values are dropped.
+	DisallowArrayOverflow bool
+
+	// DisallowIndexGaps causes Decode to return an error when indexed
+	// keys like a[0], a[2] skip an index.  By default missing elements are
+	// left at their zero value.
+	DisallowIndexGaps bool
+
+	// CollectErrors causes Decode to keep going after a field fails to
+	// parse and return all of the failures together as a MultiError.  By
+	// default Decode returns the first failure.
+	CollectErrors bool
+
+	// DecimalOnly parses integers strictly in base 10.  By default they
+	// may also use Go literal syntax, such as 0xff, 0b1010 and 1_000.
+	// The decimal tag option sets DecimalOnly for a single field.
+	DecimalOnly bool
+
+	// DecimalComma reads float and json.Number values written with a
+	// comma as the decimal separator and periods grouping thousands, as
+	// many European locales do, so that "1.234,56" decodes as 1234.56.
+	// Periods must group digits in threes, so "1.5" is an error.
+	DecimalComma bool
+
+	// SkipEmpty leaves fields at their zero value when given an empty
+	// string, as HTML forms send for unset inputs, instead of failing to
+	// parse it.  String fields are still set to "".
+	SkipEmpty bool
+
+	// ForbidKinds lists kinds of struct fields that Decode leaves
+	// untouched, as a precaution against untrusted input building deep or
+	// loosely typed values.  Pointers, slices and arrays of these kinds
+	// are also skipped.  Keys for skipped fields are treated as unknown,
+	// so with DisallowUnknownFields they are an error.
+	ForbidKinds []reflect.Kind
+
+	// DisallowEmpty causes Decode to return ErrEmpty when the input has
+	// no keys at all.  By default empty input decodes successfully.
+	DisallowEmpty bool
+
+	// MaxBytes, if positive, limits how much DecodeFrom will read.
+	// Longer input causes ErrTooLarge.  For compressed input the limit
+	// applies to the decompressed data.
+	MaxBytes int64
+
+	// Compression is how the input to DecodeFrom and the bodies read by
+	// DecodeRequest are compressed.  The default is NoCompression.
+	Compression Compression
+
+	// Precedence controls how DecodeRequest combines the URL query with
+	// the body of POST, PUT and PATCH requests.  The default, PreferBody,
+	// matches http.Request.FormValue.
+	Precedence Precedence
+
+	// Validate, if set, is called with the object passed to Decode after
+	// it has been decoded without error.  Its error, if any, is returned
+	// from Decode.
+	Validate func(obj interface{}) error
+
+	// quoted is set for fields with the string option, whose values may
+	// be wrapped in double quotes as they would be in JSON.
+	quoted bool
+
+	// split is the delimiter set by a field's split option, used to
+	// break single values into several elements of a slice or array.
+	split string
+
+	// as is the type named by a field's as option, which interface
+	// values are decoded as instead of inferring their type.
+	as string
+
+	// ctx is set by DecodeContext.
+	ctx context.Context
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{}
+}
+
+// Clone returns a copy of d that can be changed without affecting d.
+func (d *Decoder) Clone() *Decoder {
+	dc := *d
+	if d.TimeLayouts != nil {
+		dc.TimeLayouts = append([]string{}, d.TimeLayouts...)
+	}
+	if d.ForbidKinds != nil {
+		dc.ForbidKinds = append([]reflect.Kind{}, d.ForbidKinds...)
+	}
+	return &dc
+}
+
+// WithTagName returns a copy of d that reads field names and options
+// from the tagName struct tag.
+func (d *Decoder) WithTagName(tagName string) *Decoder {
+	dc := d.Clone()
+	dc.TagName = tagName
+	return dc
+}
+
+// WithStrict returns a copy of d with DisallowUnknownFields set to
+// strict.
+func (d *Decoder) WithStrict(strict bool) *Decoder {
+	dc := d.Clone()
+	dc.DisallowUnknownFields = strict
+	return dc
+}
+
+// WithDisallowDuplicateKeys returns a copy of d with
+// DisallowDuplicateKeys set to disallow.
+func (d *Decoder) WithDisallowDuplicateKeys(disallow bool) *Decoder {
+	dc := d.Clone()
+	dc.DisallowDuplicateKeys = disallow
+	return dc
+}
+
+// forbidden reports whether fields of type t are skipped because of
+// ForbidKinds.
+func (d *Decoder) forbidden(t reflect.Type) bool {
+	if len(d.ForbidKinds) == 0 {
+		return false
+	}
+	for {
+		for _, k := range d.ForbidKinds {
+			if t.Kind() == k {
+				return true
+			}
+		}
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+type decodeState struct {
+	*Decoder
+	unknown []string
+	missing []string
+	errs    []error
+	// raw is the undecoded input for fields with the raw option, or nil
+	// if there wasn't any and query should be encoded instead.
+	raw   []byte
+	query url.Values
+	// elems is the number of slice elements that indexed keys may still
+	// allocate, shared by every slice in the decode.
+	elems int
+}
+
+// fieldError attributes err to key.  When collecting errors it records
+// err and returns nil, otherwise it returns err to abort the decode.
+func (ds *decodeState) fieldError(key string, err error) error {
+	err = keyError(key, err)
+	if !ds.CollectErrors {
+		return err
+	}
+	if cerr := ds.canceled(); cerr != nil {
+		return cerr
+	}
+	ds.errs = append(ds.errs, err)
+	return nil
+}
+
+// DecodeContext is like Decode but gives up with ctx.Err() if ctx is
+// cancelled while decoding.
+func (d *Decoder) DecodeContext(ctx context.Context, data []byte, obj interface{}) error {
+	dc := *d
+	dc.ctx = ctx
+	return dc.Decode(data, obj)
+}
+
+// DecodeFrom reads all of r and decodes it like Decode.
+func (d *Decoder) DecodeFrom(r io.Reader, obj interface{}) error {
+	data, err := d.readAll(r)
+	if err != nil {
+		return err
+	}
+	return d.Decode(data, obj)
+}
+
+// readAll decompresses and reads all of r, up to MaxBytes.
+func (d *Decoder) readAll(r io.Reader) ([]byte, error) {
+	if d.Compression != NoCompression {
+		// An empty body is empty input rather than a missing header.
+		br := bufio.NewReader(r)
+		if _, err := br.Peek(1); err == io.EOF {
+			return nil, nil
+		}
+		r = br
+	}
+	zr, err := d.Compression.reader(r)
+	if err != nil {
+		return nil, fmt.Errorf("form: can't decompress input: %w", err)
+	}
+	defer zr.Close()
+	r = zr
+	if d.MaxBytes > 0 {
+		r = io.LimitReader(r, d.MaxBytes+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if d.MaxBytes > 0 && int64(len(data)) > d.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// canceled returns the context error once the context passed to
+// DecodeContext is done.
+func (d *Decoder) canceled() error {
+	if d.ctx == nil {
+		return nil
+	}
+	return d.ctx.Err()
+}
+
+// parseQuery is url.ParseQuery, with any syntax error marked as such.
+func parseQuery(data []byte) (url.Values, error) {
+	query, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, invalidQuery(err)
+	}
+	return query, nil
+}
+
+// Decode parses form data and stores the result in obj.  Empty data is
+// not an error unless DisallowEmpty is set: struct fields are left as
+// they are, apart from applying defaults and reporting required fields.
+func (d *Decoder) Decode(data []byte, obj interface{}) error {
+	if len(data) == 0 && d.DisallowEmpty {
+		return ErrEmpty
+	}
+	if isNilPointer(obj) {
+		return errNilPointer
+	}
+	if tobj, ok := obj.(FormUnmarshaler); ok {
+		return d.validate(obj, tobj.UnmarshalForm(data))
+	}
+	query, err := parseQuery(data)
+	if err != nil {
+		return err
+	}
+	return d.decodeValues(query, data, obj)
+}
+
+// DecodeValues is like Decode but takes form data that has already been
+// parsed, such as from http.Request.URL.Query.
+func (d *Decoder) DecodeValues(query url.Values, obj interface{}) error {
+	return d.decodeValues(query, nil, obj)
+}
+
+// decodeValues implements DecodeValues, with raw as the input that query
+// was parsed from, if there is any.
+func (d *Decoder) decodeValues(query url.Values, raw []byte, obj interface{}) error {
+	return d.validate(obj, d.unmarshalValues(query, raw, obj))
+}
+
+var errNilPointer = &sentinelError{sentinel: ErrNotPointer, msg: "nil pointer"}
+
+// isNilPointer reports whether obj is a nil pointer of any type.
+func isNilPointer(obj interface{}) bool {
+	rv := reflect.ValueOf(obj)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// validate calls Validate with obj if decoding it succeeded.
+func (d *Decoder) validate(obj interface{}, err error) error {
+	if err != nil || d.Validate == nil {
+		return err
+	}
+	return d.Validate(obj)
+}
+
+func (d *Decoder) unmarshalValues(query url.Values, raw []byte, obj interface{}) error {
+	if isNilPointer(obj) {
+		return errNilPointer
+	}
+	if len(query) == 0 && d.DisallowEmpty {
+		return ErrEmpty
+	}
+	switch tobj := obj.(type) {
+	case FormUnmarshaler:
+		return tobj.UnmarshalForm([]byte(query.Encode()))
+	case *url.Values:
+		if query == nil {
+			query = url.Values{}
+		}
+		*tobj = query
+		return nil
+	}
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr {
+		return ErrNotPointer
+	}
+	if pv := rv.Elem(); pv.Kind() == reflect.Ptr {
+		if pv.Type().Elem().Kind() == reflect.Ptr {
+			return unsupportedf("can't unmarshal to %T", obj)
+		}
+		if pv.IsNil() {
+			pv.Set(reflect.New(pv.Type().Elem()))
+		}
+		return d.unmarshalValues(query, raw, pv.Interface())
+	}
+	rv = rv.Elem()
+	ds := &decodeState{Decoder: d, raw: raw, query: query, elems: maxIndex + len(query)}
+	var err error
+	switch rv.Kind() {
+	case reflect.Struct:
+		err = ds.unmarshalStruct(rv, query, "")
+	case reflect.Map:
+		m := reflect.MakeMap(rv.Type())
+		err = ds.unmarshalMap(m, query)
+		unknown := d.DisallowUnknownFields && len(ds.unknown) > 0
+		missing := !d.CollectErrors && len(ds.missing) > 0
+		if err == nil && !unknown && !missing {
+			mergeMap(rv, m)
+		}
+	case reflect.Slice, reflect.Array:
+		if isBytes(rv.Type()) {
+			return unsupportedf("can't unmarshal to %T", obj)
+		}
+		err = ds.unmarshalTopLevel(rv, query)
+	default:
+		return unsupportedf("can't unmarshal to %T", obj)
+	}
+	if err != nil {
+		return err
+	}
+	if len(ds.missing) > 0 {
+		sort.Strings(ds.missing)
+		err := &MissingFieldsError{Keys: ds.missing}
+		if !d.CollectErrors {
+			return err
+		}
+		ds.errs = append(ds.errs, err)
+	}
+	if len(ds.errs) > 0 {
+		return MultiError(ds.errs)
+	}
+	if d.DisallowUnknownFields && len(ds.unknown) > 0 {
+		sort.Strings(ds.unknown)
+		return &UnknownFieldsError{Keys: ds.unknown}
+	}
+	return nil
+}
+
+// unmarshalTopLevel decodes keys like [0][name] and [] into the slice or
+// array rv, the inverse of encoding a slice at the top level.
+func (ds *decodeState) unmarshalTopLevel(rv reflect.Value, query url.Values) error {
+	sub := url.Values{}
+	for k, vals := range query {
+		if strings.HasPrefix(k, "[") {
+			if j := strings.IndexByte(k, ']'); j > 0 {
+				sub[k[1:j]+k[j+1:]] = vals
+				continue
+			}
+		}
+		ds.unknown = append(ds.unknown, k)
+	}
+	return ds.unmarshalIndexed(rv, sub, "", ds.Decoder)
+}
+
+// unmarshalMap decodes query into the map rv.  When the map holds
+// interface values, bracketed keys like a[b] are decoded into nested
+// map[string]interface{} values rather than used as literal keys, and
+// when it holds structs they are decoded into the struct for a.  If
+// there is also a flat key a, its value is kept and the bracketed keys
+// are dropped.
+//
+// Decode passes unmarshalMap an empty map and only merges it into the
+// destination if it succeeds, so that a bad value doesn't leave the
+// destination half written.  With CollectErrors the valid entries are
+// merged and the rest are reported.
+func (ds *decodeState) unmarshalMap(rv reflect.Value, query url.Values) error {
+	rt := rv.Type()
+	group := isInterfaceMapType(rt.Elem()) || isNestedType(rt.Elem())
+	nested := map[string]url.Values{}
+	for _, key := range sortedKeys(query) {
+		if err := ds.canceled(); err != nil {
+			return err
+		}
+		vals := query[key]
+		if group {
+			base, sub, ok := splitKey(key)
+			if ok {
+				if nested[base] == nil {
+					nested[base] = url.Values{}
+				}
+				nested[base][sub] = vals
+				continue
+			}
+		}
+		if rt.Elem().Kind() == reflect.String && len(vals) > 1 {
+			if ds.DisallowDuplicateMapKeys {
+				return fmt.Errorf("form: multiple values for key %q", key)
+			}
+			vals = vals[len(vals)-1:]
+		}
+		kv := reflect.New(rt.Key())
+		err := ds.fromString(key, kv.Interface())
+		if err == nil {
+			pv := reflect.New(rt.Elem())
+			err = ds.fromStrings(vals, pv.Interface())
+			if err == nil {
+				rv.SetMapIndex(kv.Elem(), pv.Elem())
+				continue
+			}
+		}
+		if err = ds.fieldError(key, err); err != nil {
+			return err
+		}
+	}
+	bases := make([]string, 0, len(nested))
+	for base := range nested {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+	for _, base := range bases {
+		if _, ok := query[base]; ok {
+			if ds.DisallowDuplicateMapKeys || ds.DisallowUnknownFields {
+				return fmt.Errorf("form: key %q has both a value and nested keys", base)
+			}
+			continue
+		}
+		kv := reflect.New(rt.Key())
+		err := ds.fromString(base, kv.Interface())
+		if err != nil {
+			if err = ds.fieldError(base, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if rt.Elem().Kind() != reflect.Interface {
+			ev := reflect.New(rt.Elem()).Elem()
+			err = ds.unmarshalNested(ev, nested[base], base, ds.Decoder)
+			if err != nil {
+				return err
+			}
+			rv.SetMapIndex(kv.Elem(), ev)
+			continue
+		}
+		m := map[string]interface{}{}
+		err = ds.unmarshalMap(reflect.ValueOf(m), nested[base])
+		if err != nil {
+			return err
+		}
+		rv.SetMapIndex(kv.Elem(), reflect.ValueOf(m))
+	}
+	return nil
+}
+
+var interfaceMapType = reflect.TypeOf(map[string]interface{}{})
+
+// isInterfaceMapType reports whether t is an interface type that can hold
+// the map[string]interface{} values built from bracketed keys.
+func isInterfaceMapType(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && interfaceMapType.AssignableTo(t)
+}
+
+// isNestedType reports whether values of type t, or of the type t points
+// to, are structs decoded from bracketed keys rather than single values.
+func isNestedType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return isNestedStruct(reflect.New(t).Elem())
+}
+
+// mergeMap copies the entries of src into the map dst, which is set to
+// src if it is nil.
+func mergeMap(dst, src reflect.Value) {
+	if dst.IsNil() {
+		dst.Set(src)
+		return
+	}
+	iter := src.MapRange()
+	for iter.Next() {
+		dst.SetMapIndex(iter.Key(), iter.Value())
+	}
+}
+
+// splitKey splits a bracketed key like "a[b][c]" into its base "a" and
+// the remaining path "b[c]".
+func splitKey(key string) (string, string, bool) {
+	i := strings.IndexByte(key, '[')
+	if i <= 0 {
+		return key, "", false
+	}
+	j := strings.IndexByte(key[i:], ']')
+	if j < 0 {
+		return key, "", false
+	}
+	j += i
+	return key[:i], key[i+1:j] + key[j+1:], true
+}
+
+// withFieldOptions returns a decoder with any options set in a field's
+// tag applied.
+func (d *Decoder) withFieldOptions(opts tagOptions) *Decoder {
+	if len(opts) == 0 {
+		return d
+	}
+	fd := *d
+	if layout, ok := opts.Get("layout"); ok {
+		fd.TimeLayouts = []string{layout}
+	}
+	fd.quoted = opts.Contains("string")
+	if opts.Contains("decimal") {
+		fd.DecimalOnly = true
+	}
+	fd.split = splitDelimiter(opts)
+	fd.as, _ = opts.Get("as")
+	fd.UnixTime = unixTime(opts, d.UnixTime)
+	return &fd
+}
+
+// unmarshalNested decodes the bracketed keys under prefix into fv.
+func (ds *decodeState) unmarshalNested(fv reflect.Value, sub url.Values, prefix string, fd *Decoder) error {
+	if fv.Kind() == reflect.Ptr {
+		switch fv.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array:
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		return ds.unmarshalStruct(fv, sub, prefix)
+	case reflect.Slice, reflect.Array:
+		if !isBytes(fv.Type()) {
+			return ds.unmarshalIndexed(fv, sub, prefix, fd)
+		}
+	}
+	for k := range sub {
+		ds.unknown = append(ds.unknown, joinKey(prefix, k))
+	}
+	return nil
+}
+
+// maxIndex limits the length of a slice built from indexed keys, so that
+// a key like a[1000000000] can't exhaust memory.  The slices built in a
+// single decode are also limited to maxIndex elements in total, plus one
+// for each key, so that many nested keys like a[65535][b][65535] can't
+// either.
+const maxIndex = 1 << 16
+
+// unmarshalIndexed decodes keys like a[0], a[1] into a slice or array.
+// Values for a[], and for a plain key a given alongside indexed keys, are
+// appended after the highest index.  Elements that
+// are structs are decoded from keys like a[0][name], a[1][name].
+func (ds *decodeState) unmarshalIndexed(fv reflect.Value, sub url.Values, prefix string, fd *Decoder) error {
+	indexed := map[int][]string{}
+	nested := map[int]url.Values{}
+	keys := map[int]string{}
+	n := 0
+	for _, k := range sortedKeys(sub) {
+		if err := ds.canceled(); err != nil {
+			return err
+		}
+		if k == "" {
+			continue
+		}
+		base, rest, isNested := splitKey(k)
+		i, err := strconv.Atoi(base)
+		if err != nil || i < 0 {
+			ds.unknown = append(ds.unknown, joinKey(prefix, k))
+			continue
+		}
+		if i >= maxIndex {
+			err = &FieldError{Value: base, Type: fv.Type(), Err: errors.New("index out of range")}
+			if err = ds.fieldError(joinKey(prefix, k), err); err != nil {
+				return err
+			}
+			continue
+		}
+		if isNested {
+			if nested[i] == nil {
+				nested[i] = url.Values{}
+			}
+			nested[i][rest] = sub[k]
+		} else {
+			indexed[i] = sub[k]
+		}
+		keys[i] = base
+		if i >= n {
+			n = i + 1
+		}
+	}
+	if fd.DisallowIndexGaps && len(keys) != n {
+		err := &FieldError{Type: fv.Type(), Err: errors.New("missing index")}
+		for i := 0; i < n; i++ {
+			if _, ok := keys[i]; !ok {
+				err.Value = strconv.Itoa(i)
+				break
+			}
+		}
+		if err := ds.fieldError(prefix, err); err != nil {
+			return err
+		}
+	}
+	appended := sub[""]
+	length := n + len(appended)
+	var pv reflect.Value
+	if fv.Kind() == reflect.Array {
+		if length > fv.Len() && fd.DisallowArrayOverflow {
+			err := fmt.Errorf("too many values for array of length %d", fv.Len())
+			return ds.fieldError(prefix, &FieldError{Type: fv.Type(), Err: err})
+		}
+		pv = reflect.New(fv.Type()).Elem()
+	} else {
+		if length > ds.elems {
+			err := &FieldError{Type: fv.Type(), Err: errors.New("too many elements")}
+			return ds.fieldError(prefix, err)
+		}
+		ds.elems -= length
+		pv = reflect.MakeSlice(fv.Type(), length, length)
+	}
+	for i := 0; i < n && i < pv.Len(); i++ {
+		if vals, ok := indexed[i]; ok {
+			err := fd.fromStrings(vals, pv.Index(i).Addr().Interface())
+			if err != nil {
+				err = ds.fieldError(joinKey(prefix, keys[i]), err)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if esub, ok := nested[i]; ok {
+			err := ds.unmarshalNested(pv.Index(i), esub, joinKey(prefix, keys[i]), fd)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for j, v := range appended {
+		if n+j >= pv.Len() {
+			break
+		}
+		err := fd.fromString(v, pv.Index(n+j).Addr().Interface())
+		if err != nil {
+			err = ds.fieldError(prefix+"[]", err)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	fv.Set(pv)
+	return nil
+}
+
+func sortedKeys(query url.Values) []string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// joinKey is the inverse of splitKey, prefixing key with a base.
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	i := strings.IndexByte(key, '[')
+	if i < 0 {
+		return prefix + "[" + key + "]"
+	}
+	return prefix + "[" + key[:i] + "]" + key[i:]
+}
+
+func (ds *decodeState) unmarshalStruct(rv reflect.Value, query url.Values, prefix string) error {
+	sf := cachedFields(rv.Type(), ds.TagName)
+	if sf.keysErr != nil && len(query) > 0 {
+		return sf.keysErr
+	}
+	fields, keys := sf.list, sf.keys
+	fold := func(k string) string { return k }
+	if ds.CaseInsensitive {
+		var err error
+		keys, err = sf.foldedKeys()
+		if err != nil {
+			return err
+		}
+		fold = strings.ToLower
+	}
+	nested := map[int]url.Values{}
+	bases := map[int]string{}
+	seen := map[int]bool{}
+	// counts is the number of values for each field, which can come
+	// from more than one key when a field has aliases.
+	counts := map[int]int{}
+	// flat holds the values of repeated keys for slice fields, which are
+	// appended after any indexed keys for the same field.
+	flat := map[int][]string{}
+	for _, k := range sortedKeys(query) {
+		if err := ds.canceled(); err != nil {
+			return err
+		}
+		vals := query[k]
+		i, ok := keys[fold(k)]
+		ok = ok && !ds.forbidden(fields[i].typ)
+		if ok {
+			seen[i] = true
+		} else if s, ok := sf.setters[fold(k)]; ok && ds.UseSetters {
+			err := callSetter(rv, s, vals)
+			if err != nil {
+				err = ds.fieldError(joinKey(prefix, k), err)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		} else {
+			base, sub, ok := splitKey(k)
+			if ok {
+				i, ok = keys[fold(base)]
+				ok = ok && !ds.forbidden(fields[i].typ)
+			}
+			if !ok {
+				if sf.rest >= 0 && !ds.forbidden(fields[sf.rest].typ) {
+					if err := addRest(settableFieldByIndex(rv, fields[sf.rest].index), k, vals); err != nil {
+						return err
+					}
+					continue
+				}
+				ds.unknown = append(ds.unknown, joinKey(prefix, k))
+				continue
+			}
+			if nested[i] == nil {
+				nested[i] = url.Values{}
+				bases[i] = base
+			}
+			nested[i][sub] = vals
+			seen[i] = true
+			continue
+		}
+		f := fields[i]
+		if isPresenceField(f) {
+			settableFieldByIndex(rv, f.index).SetBool(true)
+			continue
+		}
+		if allEmpty(vals) && f.opts.Contains("required") && f.opts.Contains("nonempty") {
+			ds.missing = append(ds.missing, joinKey(prefix, k))
+			continue
+		}
+		counts[i] += len(vals)
+		if ds.DisallowDuplicateKeys && counts[i] > 1 && !isMultiValued(f.typ) {
+			err := ds.fieldError(joinKey(prefix, k), fmt.Errorf("form: multiple values for key %q", joinKey(prefix, k)))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		v := reflect.New(f.typ)
+		err := ds.withFieldOptions(f.opts).fromStrings(vals, v.Interface())
+		if err != nil {
+			err = ds.fieldError(joinKey(prefix, k), err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		settableFieldByIndex(rv, f.index).Set(v.Elem())
+		if isMultiValued(f.typ) {
+			flat[i] = append(flat[i], vals...)
+		}
+	}
+	for i, f := range fields {
+		if ds.forbidden(f.typ) {
+			continue
+		}
+		if f.raw {
+			if prefix == "" {
+				if err := ds.setRaw(settableFieldByIndex(rv, f.index)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.rest {
+			continue
+		}
+		if !seen[i] {
+			err := ds.unmarshalMissing(rv, f, prefix)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		sub, ok := nested[i]
+		if !ok {
+			continue
+		}
+		fd := ds.withFieldOptions(f.opts)
+		if vals, ok := flat[i]; ok {
+			sub[""] = append(fd.splitValues(vals), sub[""]...)
+		}
+		fv := settableFieldByIndex(rv, f.index)
+		err := ds.unmarshalNested(fv, sub, joinKey(prefix, bases[i]), fd)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMultiValued reports whether a field of type t can hold more than one
+// value from a repeated key.
+func isMultiValued(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
+var stringsType = reflect.TypeOf([]string{})
+
+// addRest adds a key that didn't match any field to the map field fv with
+// the rest option.
+func addRest(fv reflect.Value, key string, vals []string) error {
+	if fv.Kind() != reflect.Map || fv.Type().Key().Kind() != reflect.String || fv.Type().Elem() != stringsType {
+		return unsupportedf("can't store unknown keys in %s", fv.Type())
+	}
+	if fv.IsNil() {
+		fv.Set(reflect.MakeMap(fv.Type()))
+	}
+	fv.SetMapIndex(reflect.ValueOf(key).Convert(fv.Type().Key()), reflect.ValueOf(vals))
+	return nil
+}
+
+// setRaw sets a field with the raw option to the undecoded input.
+func (ds *decodeState) setRaw(fv reflect.Value) error {
+	raw := ds.raw
+	if raw == nil {
+		raw = []byte(ds.query.Encode())
+	}
+	switch {
+	case fv.Kind() == reflect.String:
+		fv.SetString(string(raw))
+	case isBytes(fv.Type()):
+		fv.SetBytes(append([]byte{}, raw...))
+	default:
+		return unsupportedf("can't store raw input in %s", fv.Type())
+	}
+	return nil
+}
+
+// callSetter sets an unexported field of rv to the last of vals using its
+// setter method.
+func callSetter(rv reflect.Value, s setter, vals []string) error {
+	val := ""
+	if len(vals) > 0 {
+		val = vals[len(vals)-1]
+	}
+	m := rv.Addr().MethodByName(s.method)
+	out := m.Call([]reflect.Value{reflect.ValueOf(val).Convert(m.Type().In(0))})
+	if err, _ := out[0].Interface().(error); err != nil {
+		return &FieldError{Value: val, Type: s.typ, Err: err}
+	}
+	return nil
+}
+
+// unmarshalMissing handles a field that had no keys in the input.  It is
+// recorded as missing if it is required, and otherwise set to the value
+// of its default tag option if it has one.  Nested structs get their own
+// fields' defaults.
+func (ds *decodeState) unmarshalMissing(rv reflect.Value, f field, prefix string) error {
+	key := joinKey(prefix, f.key())
+	if f.opts.Contains("required") {
+		ds.missing = append(ds.missing, key)
+		return nil
+	}
+	if isPresenceField(f) {
+		if fv, ok := fieldByIndex(rv, f.index); ok {
+			fv.SetBool(false)
+		}
+		return nil
+	}
+	def, ok := f.opts.Get("default")
+	if !ok {
+		if f.typ.Kind() != reflect.Struct || !isNestedType(f.typ) {
+			return nil
+		}
+		if fv, ok := fieldByIndex(rv, f.index); ok {
+			return ds.unmarshalStruct(fv, url.Values{}, key)
+		}
+		// The field is inside a nil embedded pointer, which is only
+		// allocated if the nested struct has defaults to apply.
+		fv := reflect.New(f.typ).Elem()
+		if err := ds.unmarshalStruct(fv, url.Values{}, key); err != nil {
+			return err
+		}
+		if !fv.IsZero() {
+			settableFieldByIndex(rv, f.index).Set(fv)
+		}
+		return nil
+	}
+	v := reflect.New(f.typ)
+	err := ds.withFieldOptions(f.opts).fromStrings([]string{def}, v.Interface())
+	if err != nil {
+		return ds.fieldError(key, err)
+	}
+	settableFieldByIndex(rv, f.index).Set(v.Elem())
+	return nil
+}
+
+// isPresenceField reports whether f is a bool with the presence option,
+// which is true when its key is in the input, whatever the value, and
+// false otherwise, like an HTML checkbox.
+func isPresenceField(f field) bool {
+	return f.typ.Kind() == reflect.Bool && f.opts.Contains("presence")
+}
+
+// allEmpty reports whether every one of vals is "".
+func allEmpty(vals []string) bool {
+	for _, v := range vals {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,1326 @@
+package form
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderTimeLayouts(t *testing.T) {
+	dec := NewDecoder()
+	dec.TimeLayouts = []string{"02/01/2006"}
+	x := &testStruct{}
+	err := dec.Decode([]byte("birth=09/10/1940"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC), x.Birthdate)
+
+	m := map[string]interface{}{}
+	err = dec.Decode([]byte("birth=09/10/1940"), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC), m["birth"])
+
+	err = dec.Decode([]byte("birth=1940-10-09"), x)
+	assert.NotNil(t, err)
+}
+
+func TestDecoderLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	dec := NewDecoder()
+	dec.Location = loc
+	x := &testStruct{}
+	err = dec.Decode([]byte("birth=2024-03-10"), x)
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2024, time.March, 10, 5, 0, 0, 0, time.UTC).Equal(x.Birthdate))
+	assert.Equal(t, loc, x.Birthdate.Location())
+
+	err = dec.Decode([]byte("birth=2024-03-10T12:00:00%2B02:00"), x)
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC).Equal(x.Birthdate))
+
+	m := map[string]interface{}{}
+	err = dec.Decode([]byte("birth=2024-03-10"), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 10, 0, 0, 0, 0, loc), m["birth"])
+}
+
+type ambiguousStruct struct {
+	Name     string
+	Nickname string `json:"NAME"`
+}
+
+func TestDecoderCaseInsensitive(t *testing.T) {
+	dec := NewDecoder()
+	dec.CaseInsensitive = true
+	for _, key := range []string{"NAME", "Name", "name", "nAmE"} {
+		x := &user{}
+		err := dec.Decode([]byte(key+"=John&ADDRESS[Street]=Main"), x)
+		assert.Nil(t, err, key)
+		assert.Equal(t, "John", x.Name, key)
+		assert.Equal(t, "Main", x.Address.Street, key)
+	}
+
+	x := &user{}
+	err := UnmarshalForm([]byte("NAME=John"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, "", x.Name)
+
+	y := &ambiguousStruct{}
+	err = UnmarshalForm([]byte("Name=John&NAME=Johnny"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, &ambiguousStruct{Name: "John", Nickname: "Johnny"}, y)
+	err = dec.Decode([]byte("name=John"), y)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: fields Nickname and Name of form.ambiguousStruct both match key "name" ignoring case`, err.Error())
+}
+
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	data := []byte("name=John&foo=1&address[street]=Main&address[zip]=10001&bar=2")
+	x := &user{}
+	err := UnmarshalForm(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, "Main", x.Address.Street)
+
+	dec := NewDecoder()
+	dec.DisallowUnknownFields = true
+	x = &user{}
+	err = dec.Decode(data, x)
+	ufe, ok := err.(*UnknownFieldsError)
+	assert.True(t, ok, "err is *UnknownFieldsError")
+	assert.Equal(t, []string{"address[zip]", "bar", "foo"}, ufe.Keys)
+	assert.Equal(t, `unknown form fields "address[zip]", "bar", "foo"`, err.Error())
+
+	err = dec.Decode([]byte("name=John&foo=1"), x)
+	assert.Equal(t, `unknown form field "foo"`, err.Error())
+
+	err = dec.Decode([]byte("name=John&address[city]=NYC"), x)
+	assert.Nil(t, err)
+}
+
+func TestDecoderCollectErrors(t *testing.T) {
+	data := []byte("name=John&age=old&numbers=1&numbers=two&address[street]=Main")
+	x := &testStruct{}
+	err := UnmarshalForm(data, x)
+	assert.NotNil(t, err)
+	_, ok := err.(MultiError)
+	assert.False(t, ok, "err is not a MultiError")
+
+	dec := NewDecoder()
+	dec.CollectErrors = true
+	x = &testStruct{}
+	err = dec.Decode(data, x)
+	merr, ok := err.(MultiError)
+	assert.True(t, ok, "err is a MultiError")
+	assert.Equal(t, 2, len(merr))
+	assert.Equal(t, `form: field "age" (float64): strconv.ParseFloat: parsing "old": invalid syntax`, merr[0].Error())
+	assert.Equal(t, `form: field "numbers" (int): strconv.ParseInt: parsing "two": invalid syntax`, merr[1].Error())
+	assert.Equal(t, []string{"John"}, x.Name)
+}
+
+func TestFieldError(t *testing.T) {
+	x := &testStruct{}
+	err := UnmarshalForm([]byte("age=old"), x)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "age", fe.Key)
+	assert.Equal(t, "old", fe.Value)
+	assert.Equal(t, reflect.TypeOf(float64(0)), fe.Type)
+	assert.True(t, errors.Is(err, strconv.ErrSyntax), "err wraps strconv.ErrSyntax")
+
+	y := &user{}
+	err = UnmarshalForm([]byte("address[street]=Main&billing=x"), y)
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "billing", fe.Key)
+}
+
+func TestDecodeStringMap(t *testing.T) {
+	data := []byte("a=1&b=2&a=3")
+	m := map[string]string{}
+	err := UnmarshalForm(data, &m)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "3", "b": "2"}, m)
+
+	dec := NewDecoder()
+	dec.DisallowDuplicateMapKeys = true
+	m = map[string]string{}
+	err = dec.Decode(data, &m)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: multiple values for key "a"`, err.Error())
+
+	err = dec.Decode([]byte("a=1&b=2"), &m)
+	assert.Nil(t, err)
+}
+
+func TestDisallowDuplicateKeys(t *testing.T) {
+	type profile struct {
+		Name  string              `form:"name"`
+		Age   *int                `form:"age"`
+		Tags  []string            `form:"tags"`
+		Pair  [2]int              `form:"pair"`
+		Extra map[string][]string `form:",rest"`
+	}
+	data := []byte("name=a&age=5&age=6&tags=x&tags=y&pair=1&pair=2&other=1&other=2")
+	x := &profile{}
+	err := UnmarshalForm(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, *x.Age)
+
+	dec := NewDecoder().WithDisallowDuplicateKeys(true)
+	assert.False(t, NewDecoder().DisallowDuplicateKeys)
+	x = &profile{}
+	err = dec.Decode(data, x)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: multiple values for key "age"`, err.Error())
+
+	for _, q := range []string{"UserName=a&user-name=b", "user_name=a&userName=b"} {
+		err = dec.Decode([]byte(q), &struct{ UserName string }{})
+		assert.NotNil(t, err, q)
+	}
+	err = dec.WithStrict(false).Decode([]byte("name=a&NAME=b"), &profile{})
+	assert.Nil(t, err)
+	dec.CaseInsensitive = true
+	err = dec.Decode([]byte("name=a&NAME=b"), &profile{})
+	assert.NotNil(t, err)
+	dec.CaseInsensitive = false
+
+	x = &profile{}
+	err = dec.Decode([]byte("name=a&age=5&tags=x&tags=y&pair=1&pair=2&other=1&other=2"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"x", "y"}, x.Tags)
+	assert.Equal(t, [2]int{1, 2}, x.Pair)
+	assert.Equal(t, map[string][]string{"other": {"1", "2"}}, x.Extra)
+
+	dec.CollectErrors = true
+	err = dec.Decode([]byte("name=a&name=b&age=5&age=6"), &profile{})
+	var me MultiError
+	assert.True(t, errors.As(err, &me), "err is a MultiError")
+	assert.Len(t, me, 2)
+}
+
+func TestDecodeStructMap(t *testing.T) {
+	data := []byte("home[street]=Main&home[city]=Springfield&work[street]=5th")
+	m := map[string]address{}
+	err := UnmarshalForm(data, &m)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]address{
+		"home": {Street: "Main", City: "Springfield"},
+		"work": {Street: "5th"},
+	}, m)
+
+	pm := map[string]*address{}
+	err = UnmarshalForm(data, &pm)
+	assert.Nil(t, err)
+	assert.Equal(t, &address{Street: "5th"}, pm["work"])
+
+	dec := NewDecoder().WithStrict(true)
+	m = map[string]address{"old": {}}
+	err = dec.Decode([]byte("home[street]=Main&work[zz]=1"), &m)
+	assert.Equal(t, &UnknownFieldsError{Keys: []string{"work[zz]"}}, err)
+	assert.Equal(t, map[string]address{"old": {}}, m)
+
+	tm := map[string]time.Time{}
+	err = UnmarshalForm([]byte("a[b]=2020-01-02T03:04:05Z"), &tm)
+	assert.Nil(t, err)
+	assert.Contains(t, tm, "a[b]")
+}
+
+func TestForbidKinds(t *testing.T) {
+	type settings struct {
+		Name  string             `form:"name"`
+		Attrs map[string]string  `form:"attrs"`
+		Meta  *map[string]string `form:"meta"`
+		Any   interface{}        `form:"any"`
+	}
+	data := []byte("name=a&attrs[k]=v&meta[k]=v&any=1")
+	dec := NewDecoder()
+	dec.ForbidKinds = []reflect.Kind{reflect.Map}
+	x := &settings{}
+	err := dec.Decode(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, &settings{Name: "a", Any: int64(1)}, x)
+
+	dec = dec.WithStrict(true)
+	err = dec.Decode(data, &settings{})
+	var ufe *UnknownFieldsError
+	assert.True(t, errors.As(err, &ufe), "err is an *UnknownFieldsError")
+	assert.Equal(t, []string{"attrs[k]", "meta[k]"}, ufe.Keys)
+
+	dec.ForbidKinds = append(dec.ForbidKinds, reflect.Interface)
+	err = dec.Decode([]byte("name=a&any=1"), &settings{})
+	assert.True(t, errors.As(err, &ufe), "err is an *UnknownFieldsError")
+	assert.Equal(t, []string{"any"}, ufe.Keys)
+
+	y := &struct {
+		Name  string               `form:"name"`
+		Items []interface{}        `form:"i"`
+		Pairs [2]interface{}       `form:"p"`
+		Maps  []map[string]string  `form:"m"`
+		Ptrs  []*map[string]string `form:"q"`
+	}{}
+	dec = NewDecoder()
+	dec.ForbidKinds = []reflect.Kind{reflect.Interface, reflect.Map}
+	err = dec.Decode([]byte("name=a&i=1&i=x&p=1&m[0][k]=v&q[0][k]=v"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", y.Name)
+	assert.Nil(t, y.Items)
+	assert.Equal(t, [2]interface{}{}, y.Pairs)
+	assert.Nil(t, y.Maps)
+	assert.Nil(t, y.Ptrs)
+}
+
+type splitStruct struct {
+	Numbers []int     `form:"numbers,split=comma"`
+	Words   []string  `form:"words,split=space"`
+	Tags    [3]string `form:"tags,split=pipe"`
+}
+
+type skipEmptyStruct struct {
+	Name  string    `json:"name"`
+	Age   int       `json:"age"`
+	Score *float64  `json:"score"`
+	Admin bool      `json:"admin"`
+	Birth time.Time `json:"birth"`
+}
+
+func TestDecoderSkipEmpty(t *testing.T) {
+	data := []byte("name=&age=&score=&admin=&birth=")
+	x := &skipEmptyStruct{}
+	err := UnmarshalForm(data, x)
+	assert.NotNil(t, err)
+
+	dec := NewDecoder()
+	dec.SkipEmpty = true
+	x = &skipEmptyStruct{Name: "John", Age: 81}
+	err = dec.Decode(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, &skipEmptyStruct{}, x)
+
+	err = dec.Decode([]byte("name=John&age=81&score=1.5&admin=true&birth=1940-10-09T00%3A00%3A00Z"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, 81, x.Age)
+	assert.Equal(t, 1.5, *x.Score)
+	assert.True(t, x.Admin)
+	assert.Equal(t, 1940, x.Birth.Year())
+
+	y := &struct {
+		Any interface{} `form:"any,string"`
+	}{}
+	err = dec.Decode([]byte("any=%22%22&any=1"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1}, y.Any)
+	err = dec.Decode([]byte("any=&any=%22%22"), y)
+	assert.Nil(t, err)
+	assert.Nil(t, y.Any)
+}
+
+func TestDecoderDecodeFrom(t *testing.T) {
+	body := "name=John&name=Lennon&age=81.8"
+	x := &testStruct{}
+	dec := NewDecoder()
+	err := dec.DecodeFrom(strings.NewReader(body), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"John", "Lennon"}, x.Name)
+	assert.Equal(t, 81.8, x.Age)
+
+	dec.MaxBytes = int64(len(body))
+	x = &testStruct{}
+	err = dec.DecodeFrom(strings.NewReader(body), x)
+	assert.Nil(t, err)
+	assert.Equal(t, 81.8, x.Age)
+
+	dec.MaxBytes--
+	err = dec.DecodeFrom(strings.NewReader(body), x)
+	assert.Equal(t, ErrTooLarge, err)
+
+	err = dec.DecodeFrom(iotest.ErrReader(errors.New("read failed")), x)
+	assert.Equal(t, "read failed", err.Error())
+}
+
+func TestDecodeCompressed(t *testing.T) {
+	x := &testStruct{Name: []string{"John", "Lennon"}, Age: 81.8}
+	for _, c := range []Compression{Gzip, Deflate} {
+		enc := NewEncoder()
+		enc.Compression = c
+		var buf bytes.Buffer
+		err := enc.EncodeTo(&buf, x)
+		assert.Nil(t, err)
+		assert.False(t, strings.HasPrefix(buf.String(), "name="))
+
+		dec := NewDecoder()
+		dec.Compression = c
+		y := &testStruct{}
+		err = dec.DecodeFrom(bytes.NewReader(buf.Bytes()), y)
+		assert.Nil(t, err)
+		assert.Equal(t, x, y)
+
+		dec.MaxBytes = 10
+		err = dec.DecodeFrom(bytes.NewReader(buf.Bytes()), y)
+		assert.Equal(t, ErrTooLarge, err)
+
+		err = dec.DecodeFrom(strings.NewReader(""), &testStruct{})
+		assert.Nil(t, err)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(""))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		err = dec.DecodeRequest(r, &testStruct{})
+		assert.Nil(t, err)
+	}
+
+	dec := NewDecoder()
+	dec.Compression = Gzip
+	err := dec.DecodeFrom(strings.NewReader("name=John"), x)
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "form: can't decompress input: "), err.Error())
+}
+
+func TestUnmarshalValues(t *testing.T) {
+	values := url.Values{
+		"name":            {"John & Paul"},
+		"address[street]": {"Main+St"},
+		"billing[city]":   {"Springfield"},
+		"shipping[zip]":   {"ignored"},
+		"unknown%20key[]": {"x"},
+	}
+	x := &user{}
+	err := UnmarshalValues(values, x)
+	assert.Nil(t, err)
+	assert.Equal(t, "John & Paul", x.Name)
+	assert.Equal(t, "Main+St", x.Address.Street)
+	assert.Equal(t, "Springfield", x.Billing.City)
+
+	dec := NewDecoder()
+	dec.DisallowUnknownFields = true
+	err = dec.DecodeValues(values, x)
+	assert.Equal(t, &UnknownFieldsError{Keys: []string{"shipping[zip]", "unknown%20key[]"}}, err)
+
+	m := map[string]string{}
+	err = UnmarshalValues(url.Values{"a": {"1"}}, &m)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, m)
+}
+
+type defaultAddress struct {
+	City    string `json:"city" form:",default=Springfield"`
+	Country string `json:"country" form:",default=US"`
+}
+
+type defaultStruct struct {
+	Page    int            `form:"page,default=1"`
+	Size    *int           `form:"size,default=10"`
+	Active  bool           `form:"active,default=true"`
+	Since   time.Time      `form:"since,default=2024-01-01T00:00:00Z"`
+	Tags    []string       `form:"tags,split=pipe,default=a|b"`
+	Name    string         `form:"name"`
+	Address defaultAddress `form:"address"`
+}
+
+type PlainEmbed struct {
+	When time.Time `form:"when"`
+	Home address   `form:"home"`
+}
+
+type DefaultsEmbed struct {
+	Address defaultAddress `form:"address"`
+}
+
+func TestDecodeMissingNested(t *testing.T) {
+	x := &struct {
+		*PlainEmbed
+		X int `form:"x"`
+	}{}
+	err := UnmarshalForm([]byte("x=1"), x)
+	assert.Nil(t, err)
+	assert.Nil(t, x.PlainEmbed)
+
+	y := &struct {
+		*DefaultsEmbed
+		X int `form:"x"`
+	}{}
+	err = UnmarshalForm([]byte("x=1"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, &DefaultsEmbed{Address: defaultAddress{City: "Springfield", Country: "US"}}, y.DefaultsEmbed)
+
+	z := &struct {
+		Name string
+		C    collidingStruct
+	}{}
+	err = UnmarshalForm([]byte("name=x"), z)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", z.Name)
+	err = UnmarshalForm([]byte("c[user_id]=1"), z)
+	assert.NotNil(t, err)
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	x := &defaultStruct{}
+	err := UnmarshalForm([]byte("name=John"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, x.Page)
+	assert.Equal(t, 10, *x.Size)
+	assert.True(t, x.Active)
+	assert.Equal(t, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), x.Since)
+	assert.Equal(t, []string{"a", "b"}, x.Tags)
+	assert.Equal(t, defaultAddress{City: "Springfield", Country: "US"}, x.Address)
+
+	x = &defaultStruct{}
+	err = UnmarshalForm([]byte("page=3&size=20&active=false&tags=c&address[city]=Shelbyville"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, x.Page)
+	assert.Equal(t, 20, *x.Size)
+	assert.False(t, x.Active)
+	assert.Equal(t, []string{"c"}, x.Tags)
+	assert.Equal(t, defaultAddress{City: "Shelbyville", Country: "US"}, x.Address)
+
+	x = &defaultStruct{}
+	err = UnmarshalForm([]byte("tags=&address[city]="), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, x.Tags)
+	assert.Equal(t, defaultAddress{City: "", Country: "US"}, x.Address)
+	err = UnmarshalForm([]byte("page="), x)
+	assert.NotNil(t, err)
+
+	type badDefault struct {
+		N int `form:"n,default=x"`
+	}
+	err = UnmarshalForm([]byte(""), &badDefault{})
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "n", fe.Key)
+}
+
+type requiredStruct struct {
+	Name  string `form:"name,required"`
+	Email string `form:"email,required,nonempty"`
+	Age   int    `form:"age"`
+	Plan  struct {
+		ID string `form:"id,required"`
+	} `form:"plan"`
+}
+
+func TestDecodeRequired(t *testing.T) {
+	x := &requiredStruct{}
+	err := UnmarshalForm([]byte("name=John&email=john%40example.com&plan[id]=1"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, "John", x.Name)
+	assert.Equal(t, "1", x.Plan.ID)
+
+	err = UnmarshalForm([]byte("name=&email=john%40example.com&plan[id]=1"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, "", x.Name)
+
+	err = UnmarshalForm([]byte("age=1"), x)
+	assert.Equal(t, &MissingFieldsError{Keys: []string{"email", "name", "plan[id]"}}, err)
+	assert.Equal(t, `missing required form fields "email", "name", "plan[id]"`, err.Error())
+
+	err = UnmarshalForm([]byte("name=John&email=&plan[id]=1"), x)
+	assert.Equal(t, `missing required form field "email"`, err.Error())
+
+	dec := NewDecoder()
+	dec.CollectErrors = true
+	err = dec.Decode([]byte("age=x&name=John"), x)
+	assert.Equal(t, `form: field "age" (int): strconv.ParseInt: parsing "x": invalid syntax; missing required form fields "email", "plan[id]"`, err.Error())
+	var mfe *MissingFieldsError
+	assert.True(t, errors.As(err, &mfe), "err is a *MissingFieldsError")
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	x := &testStruct{}
+	err := UnmarshalForm([]byte{}, x)
+	assert.Nil(t, err)
+	assert.Equal(t, &testStruct{}, x)
+	err = UnmarshalForm(nil, x)
+	assert.Nil(t, err)
+	assert.Equal(t, &testStruct{}, x)
+
+	err = UnmarshalForm(nil, &requiredStruct{})
+	assert.Equal(t, &MissingFieldsError{Keys: []string{"email", "name", "plan[id]"}}, err)
+
+	dec := NewDecoder()
+	dec.DisallowEmpty = true
+	for _, data := range []string{"", "&", "&&"} {
+		err = dec.Decode([]byte(data), x)
+		assert.Equal(t, ErrEmpty, err)
+	}
+	err = dec.Decode(nil, &OrderedValues{})
+	assert.Equal(t, ErrEmpty, err)
+	err = dec.DecodeValues(url.Values{}, x)
+	assert.Equal(t, ErrEmpty, err)
+	err = dec.Decode([]byte("name="), x)
+	assert.Nil(t, err)
+}
+
+type checkboxStruct struct {
+	Agree     bool `form:"agree,presence"`
+	Subscribe bool `form:"subscribe"`
+}
+
+func TestDecodePresence(t *testing.T) {
+	x := &checkboxStruct{}
+	err := UnmarshalForm([]byte("agree=on&subscribe=false"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &checkboxStruct{Agree: true}, x)
+
+	err = UnmarshalForm([]byte("agree=false&subscribe=on"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &checkboxStruct{Agree: true, Subscribe: true}, x)
+
+	err = UnmarshalForm([]byte("subscribe=on"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &checkboxStruct{Agree: false, Subscribe: true}, x)
+
+	x = &checkboxStruct{}
+	err = UnmarshalForm([]byte("agree="), x)
+	assert.Nil(t, err)
+	assert.True(t, x.Agree)
+
+	err = UnmarshalForm([]byte("subscribe=maybe"), x)
+	assert.NotNil(t, err)
+
+	y := &struct {
+		*CheckboxEmbed
+		Name string `form:"name"`
+	}{}
+	err = UnmarshalForm([]byte("name=x"), y)
+	assert.Nil(t, err)
+	assert.Nil(t, y.CheckboxEmbed)
+	err = UnmarshalForm([]byte("name=x&agree"), y)
+	assert.Nil(t, err)
+	assert.True(t, y.Agree)
+}
+
+type CheckboxEmbed struct {
+	Agree bool `form:"agree,presence"`
+}
+
+func TestDecodeIndirect(t *testing.T) {
+	var x *testStruct
+	err := UnmarshalForm([]byte("name=John&age=81.8"), &x)
+	assert.Nil(t, err)
+	assert.Equal(t, &testStruct{Name: []string{"John"}, Age: 81.8}, x)
+
+	y := x
+	err = UnmarshalForm([]byte("age=1"), &x)
+	assert.Nil(t, err)
+	assert.Same(t, y, x)
+	assert.Equal(t, 1.0, x.Age)
+
+	var m *map[string]string
+	err = UnmarshalForm([]byte("a=1"), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, *m)
+
+	var v *url.Values
+	err = UnmarshalForm([]byte("a=1"), &v)
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{"a": {"1"}}, *v)
+
+	err = UnmarshalForm([]byte("a=1"), x)
+	assert.Nil(t, err)
+	x = nil
+	err = UnmarshalForm([]byte("age=1"), x)
+	assert.Equal(t, "nil pointer", err.Error())
+	px := &x
+	err = UnmarshalForm([]byte("age=1"), &px)
+	assert.Equal(t, "can't unmarshal to ***form.testStruct", err.Error())
+}
+
+func TestDecodeNilValues(t *testing.T) {
+	var v url.Values
+	err := UnmarshalForm([]byte("a=1&a=2"), &v)
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{"a": {"1", "2"}}, v)
+
+	v = nil
+	err = UnmarshalForm(nil, &v)
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{}, v)
+
+	v = nil
+	err = UnmarshalValues(nil, &v)
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{}, v)
+}
+
+func TestDecodeInvalidQuery(t *testing.T) {
+	var ee url.EscapeError
+	x := &testStruct{}
+	err := UnmarshalForm([]byte("name=%zz"), x)
+	assert.True(t, errors.As(err, &ee), "err is a url.EscapeError")
+	assert.Equal(t, `form: invalid query string: invalid URL escape "%zz"`, err.Error())
+
+	v := url.Values{}
+	err = UnmarshalForm([]byte("name=%zz"), &v)
+	assert.True(t, errors.As(err, &ee), "err is a url.EscapeError")
+
+	ov := &OrderedValues{}
+	err = UnmarshalForm([]byte("name=%zz"), ov)
+	assert.True(t, errors.As(err, &ee), "err is a url.EscapeError")
+
+	err = UnmarshalForm([]byte("age=x"), x)
+	assert.False(t, errors.As(err, &ee), "err is not a url.EscapeError")
+}
+
+func TestSentinelErrors(t *testing.T) {
+	err := UnmarshalForm([]byte("name=%zz"), &testStruct{})
+	assert.True(t, errors.Is(err, ErrInvalidQuery), "err is ErrInvalidQuery")
+	err = UnmarshalForm([]byte("name=%zz"), &OrderedValues{})
+	assert.True(t, errors.Is(err, ErrInvalidQuery), "err is ErrInvalidQuery")
+
+	err = UnmarshalForm([]byte("name=x"), testStruct{})
+	assert.True(t, errors.Is(err, ErrNotPointer), "err is ErrNotPointer")
+	err = UnmarshalForm([]byte("name=x"), (*testStruct)(nil))
+	assert.True(t, errors.Is(err, ErrNotPointer), "err is ErrNotPointer")
+	assert.Equal(t, "nil pointer", err.Error())
+
+	for _, obj := range []interface{}{(*url.Values)(nil), (*OrderedValues)(nil), (*map[string]string)(nil)} {
+		err = UnmarshalForm([]byte("name=x"), obj)
+		assert.True(t, errors.Is(err, ErrNotPointer), "err is ErrNotPointer")
+		err = UnmarshalValues(url.Values{"name": {"x"}}, obj)
+		assert.True(t, errors.Is(err, ErrNotPointer), "err is ErrNotPointer")
+	}
+
+	var i int
+	err = UnmarshalForm([]byte("name=x"), &i)
+	assert.True(t, errors.Is(err, ErrUnsupportedType), "err is ErrUnsupportedType")
+
+	enc := NewEncoder()
+	enc.DisallowUnsupportedKinds = true
+	_, err = enc.Encode(struct{ C chan int }{})
+	assert.True(t, errors.Is(err, ErrUnsupportedType), "err is ErrUnsupportedType")
+	_, err = MarshalForm(map[string]struct{ X, Y int }{"p": {1, 2}})
+	assert.True(t, errors.Is(err, ErrUnsupportedType), "err is ErrUnsupportedType")
+}
+
+func TestDecodeSplit(t *testing.T) {
+	x := &splitStruct{}
+	err := UnmarshalForm([]byte("numbers=5,7,9&words=a+b&tags=x|y"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 7, 9}, x.Numbers)
+	assert.Equal(t, []string{"a", "b"}, x.Words)
+	assert.Equal(t, [3]string{"x", "y", ""}, x.Tags)
+
+	x = &splitStruct{}
+	err = UnmarshalForm([]byte("numbers=5,7&numbers=9&numbers="), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 7, 9}, x.Numbers)
+
+	x = &splitStruct{Numbers: []int{1, 2}, Words: []string{"a", "c"}, Tags: [3]string{"x", "y", "z"}}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "numbers=1%2C2&words=a+c&tags=x%7Cy%7Cz", string(data))
+	y := &splitStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	err = UnmarshalForm([]byte("numbers=5,x"), y)
+	assert.NotNil(t, err)
+}
+
+func TestSliceValuedMap(t *testing.T) {
+	m := map[string][]int{}
+	err := UnmarshalForm([]byte("a=3&b=1&a=1&a=2"), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]int{"a": {3, 1, 2}, "b": {1}}, m)
+
+	data, err := MarshalForm(m)
+	assert.Nil(t, err)
+	assert.Equal(t, "a=3&a=1&a=2&b=1", string(data))
+
+	data, err = MarshalForm(map[string][2]int{"a": {4, 5}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=4&a=5", string(data))
+}
+
+func TestDecodeInferredSlices(t *testing.T) {
+	m := map[string]interface{}{}
+	err := UnmarshalForm([]byte("a=1&a=2&b=1&b=x&b=2.5&c=x&c=1"), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, m["a"])
+	assert.Equal(t, []interface{}{int64(1), "x", 2.5}, m["b"])
+	assert.Equal(t, []interface{}{"x", int64(1)}, m["c"])
+
+	var x interface{}
+	err = NewDecoder().fromStrings(nil, &x)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{}, x)
+}
+
+type level int
+
+func TestNonStringMapKeys(t *testing.T) {
+	ints := map[int]string{1: "a", 2: "b", 10: "c"}
+	data, err := MarshalForm(ints)
+	assert.Nil(t, err)
+	assert.Equal(t, "1=a&10=c&2=b", string(data))
+	y := map[int]string{}
+	err = UnmarshalForm(data, &y)
+	assert.Nil(t, err)
+	assert.Equal(t, ints, y)
+
+	levels := map[level][]int{1: {5, 6}, 2: {7}}
+	data, err = MarshalForm(levels)
+	assert.Nil(t, err)
+	assert.Equal(t, "1=5&1=6&2=7", string(data))
+	z := map[level][]int{}
+	err = UnmarshalForm(data, &z)
+	assert.Nil(t, err)
+	assert.Equal(t, levels, z)
+
+	err = UnmarshalForm([]byte("x=1"), &z)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "x", fe.Key)
+	assert.Equal(t, reflect.TypeOf(level(0)), fe.Type)
+
+	err = UnmarshalForm([]byte("1=x"), &z)
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "1", fe.Key)
+}
+
+func TestDecodeMapErrors(t *testing.T) {
+	m := map[string]int{"keep": 1}
+	err := UnmarshalForm([]byte("a=1&b=x&c=3"), &m)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "b", fe.Key)
+	assert.Equal(t, map[string]int{"keep": 1}, m)
+
+	dec := NewDecoder()
+	dec.CollectErrors = true
+	err = dec.Decode([]byte("a=1&b=x&c=3&d=y"), &m)
+	merr, ok := err.(MultiError)
+	assert.True(t, ok, "err is a MultiError")
+	assert.Len(t, merr, 2)
+	assert.Equal(t, map[string]int{"keep": 1, "a": 1, "c": 3}, m)
+
+	var n map[int]int
+	err = dec.Decode([]byte("1=1&x=2"), &n)
+	assert.Len(t, err.(MultiError), 1)
+	assert.Equal(t, map[int]int{1: 1}, n)
+}
+
+func TestDecodeNestedInterfaceMap(t *testing.T) {
+	m := map[string]interface{}{}
+	err := UnmarshalForm([]byte("a[b]=1&a[c][d]=yes&a[c][e]=x&f=2.5"), &m)
+	assert.Nil(t, err)
+	expect := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": int64(1),
+			"c": map[string]interface{}{
+				"d": "yes",
+				"e": "x",
+			},
+		},
+		"f": 2.5,
+	}
+	assert.Equal(t, expect, m)
+
+	s := map[string]string{}
+	err = UnmarshalForm([]byte("a[b]=1"), &s)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a[b]": "1"}, s)
+
+	m = map[string]interface{}{}
+	err = UnmarshalForm([]byte("a[b]=1&a=2&c[d]=3&c[d][e]=4"), &m)
+	assert.Nil(t, err)
+	expect = map[string]interface{}{
+		"a": int64(2),
+		"c": map[string]interface{}{"d": int64(3)},
+	}
+	assert.Equal(t, expect, m)
+
+	dec := NewDecoder()
+	dec.DisallowDuplicateMapKeys = true
+	err = dec.Decode([]byte("a[b]=1&a=2"), &map[string]interface{}{})
+	assert.EqualError(t, err, `form: key "a" has both a value and nested keys`)
+	err = dec.Decode([]byte("c[d]=3&c[d][e]=4"), &map[string]interface{}{})
+	assert.EqualError(t, err, `form: key "d" has both a value and nested keys`)
+	err = NewDecoder().WithStrict(true).Decode([]byte("a[b]=1&a=2"), &map[string]interface{}{})
+	assert.EqualError(t, err, `form: key "a" has both a value and nested keys`)
+
+	sm := map[string]fmt.Stringer{}
+	assert.NotPanics(t, func() {
+		err = UnmarshalForm([]byte("a[b]=1"), &sm)
+	})
+	assert.NotNil(t, err)
+	assert.Empty(t, sm)
+}
+
+func TestDecodeIndexedSlices(t *testing.T) {
+	x := &testStruct{}
+	err := UnmarshalForm([]byte("numbers[1]=7&numbers[0]=5&name=John&name=Lennon"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 7}, x.FavoriteNumbers)
+	assert.Equal(t, []string{"John", "Lennon"}, x.Name)
+
+	err = UnmarshalForm([]byte("numbers[]=5&numbers[]=7&numbers[]=9"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 7, 9}, x.FavoriteNumbers)
+
+	err = UnmarshalForm([]byte("numbers[0]=5&numbers[2]=9"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 0, 9}, x.FavoriteNumbers)
+
+	err = UnmarshalForm([]byte("numbers=9&numbers[0]=5&numbers[2]=7&numbers[]=3"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 0, 7, 9, 3}, x.FavoriteNumbers)
+
+	a := &arrayStruct{}
+	err = UnmarshalForm([]byte("numbers[2]=9&numbers[0]=5&numbers[5]=1"), a)
+	assert.Nil(t, err)
+	assert.Equal(t, [3]int{5, 0, 9}, a.Numbers)
+
+	err = UnmarshalForm([]byte("numbers[100000000]=1"), x)
+	assert.NotNil(t, err)
+
+	var buf strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&buf, "items[%d][tags][65535]=x&", 65535-i)
+	}
+	items := &struct{ Items []struct{ Tags []string } }{}
+	err = UnmarshalForm([]byte(buf.String()), items)
+	assert.NotNil(t, err)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "too many elements", fe.Err.Error())
+
+	collect := NewDecoder()
+	collect.CollectErrors = true
+	collect.DisallowIndexGaps = true
+	n := &struct{ N []int }{}
+	err = collect.Decode([]byte("n[0]=1&n[1]=3&n[70000]=2"), n)
+	assert.NotNil(t, err)
+	assert.Equal(t, []int{1, 3}, n.N)
+	err = collect.Decode([]byte("n[0]=1&n[2]=3"), n)
+	assert.NotNil(t, err)
+	assert.Equal(t, []int{1, 0, 3}, n.N)
+
+	dec := NewDecoder()
+	dec.DisallowIndexGaps = true
+	err = dec.Decode([]byte("numbers[0]=5&numbers[2]=9"), x)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: field "numbers" ([]int): missing index`, err.Error())
+
+	err = dec.Decode([]byte("numbers[0]=5&numbers[1]=x"), x)
+	assert.NotNil(t, err)
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "numbers[1]", fe.Key)
+}
+
+type multiStringStruct struct {
+	Tags string `json:"tags"`
+}
+
+func TestDecoderMultiString(t *testing.T) {
+	data := []byte("tags=a&tags=b&tags=c")
+	tests := []struct {
+		mode     MultiString
+		sep      rune
+		expected string
+	}{
+		{JoinValues, 0, "a,b,c"},
+		{JoinValues, '|', "a|b|c"},
+		{FirstValue, 0, "a"},
+		{LastValue, 0, "c"},
+	}
+	for _, test := range tests {
+		dec := NewDecoder()
+		dec.MultiString = test.mode
+		dec.Separator = test.sep
+		x := &multiStringStruct{}
+		err := dec.Decode(data, x)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, x.Tags)
+	}
+}
+
+type orderItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"qty"`
+}
+
+type order struct {
+	ID    int          `json:"id"`
+	Items []orderItem  `json:"item"`
+	Gifts []*orderItem `json:"gift"`
+}
+
+func TestDecodeSliceOfStructs(t *testing.T) {
+	data := []byte("id=7&item[1][name]=b&item[0][name]=a&item[0][qty]=2&item[1][qty]=3&gift[0][name]=c")
+	x := &order{}
+	err := UnmarshalForm(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, x.ID)
+	assert.Equal(t, []orderItem{{"a", 2}, {"b", 3}}, x.Items)
+	assert.Equal(t, 1, len(x.Gifts))
+	assert.Equal(t, &orderItem{Name: "c"}, x.Gifts[0])
+
+	dec := NewDecoder()
+	dec.DisallowUnknownFields = true
+	err = dec.Decode([]byte("item[0][name]=a&item[0][color]=red"), x)
+	assert.NotNil(t, err)
+	assert.Equal(t, `unknown form field "item[0][color]"`, err.Error())
+}
+
+func TestDecodeContext(t *testing.T) {
+	data := []byte(strings.Repeat("numbers=1&", 1000) + "name=John")
+	dec := NewDecoder()
+	x := &testStruct{}
+	err := dec.DecodeContext(context.Background(), data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, len(x.FavoriteNumbers))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	x = &testStruct{}
+	err = dec.DecodeContext(ctx, data, x)
+	assert.Equal(t, context.Canceled, err)
+
+	dec.CollectErrors = true
+	err = dec.DecodeContext(ctx, data, x)
+	assert.Equal(t, context.Canceled, err)
+
+	m := map[string]interface{}{}
+	err = dec.DecodeContext(ctx, data, &m)
+	assert.Equal(t, context.Canceled, err)
+
+	err = dec.Decode(data, x)
+	assert.Nil(t, err)
+}
+
+func BenchmarkUnmarshalStruct(b *testing.B) {
+	data := []byte("name=John&address[street]=Main&address[city]=Springfield&billing[street]=Elm&billing[city]=Shelbyville")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		x := &user{}
+		if err := UnmarshalForm(data, x); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInferSlice(b *testing.B) {
+	vals := make([]string, 10000)
+	for i := range vals {
+		vals[i] = strconv.Itoa(i)
+	}
+	d := NewDecoder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var x interface{}
+		if err := d.fromStrings(vals, &x); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoderClone(t *testing.T) {
+	dec := NewDecoder()
+	dec.TimeLayouts = []string{"2006-01-02"}
+	strict := dec.WithStrict(true)
+	strict.TimeLayouts[0] = "01/02/2006"
+	assert.False(t, dec.DisallowUnknownFields)
+	assert.Equal(t, []string{"2006-01-02"}, dec.TimeLayouts)
+
+	x := &testStruct{}
+	err := dec.Decode([]byte("birthdate=1940-10-09&extra=1"), x)
+	assert.Nil(t, err)
+	err = strict.Decode([]byte("birthdate=10/09/1940&extra=1"), x)
+	assert.Equal(t, &UnknownFieldsError{Keys: []string{"extra"}}, err)
+	err = strict.Decode([]byte("birthdate=10/09/1940"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC), x.Birthdate)
+
+	enc := NewEncoder()
+	ec := enc.Clone()
+	ec.BoolStyle = BoolYesNo
+	assert.Equal(t, BoolTrueFalse, enc.BoolStyle)
+}
+
+type account struct {
+	ID    int `form:"id"`
+	email string
+	owner string `form:"owner_name"`
+	notes string
+	nick  nickname
+}
+
+type nickname string
+
+func (a *account) SetNick(n nickname) error {
+	a.nick = n
+	return nil
+}
+
+func (a *account) SetEmail(s string) error {
+	if !strings.Contains(s, "@") {
+		return errors.New("invalid email address")
+	}
+	a.email = s
+	return nil
+}
+
+func (a *account) SetOwner(s string) error {
+	a.owner = s
+	return nil
+}
+
+func TestDecodeSetters(t *testing.T) {
+	data := []byte("id=1&email=a@example.com&owner_name=Ann&notes=x")
+	x := &account{}
+	err := UnmarshalForm(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, &account{ID: 1}, x)
+
+	dec := NewDecoder()
+	dec.UseSetters = true
+	dec.DisallowUnknownFields = true
+	err = dec.Decode(data, x)
+	assert.Equal(t, &UnknownFieldsError{Keys: []string{"notes"}}, err)
+	assert.Equal(t, &account{ID: 1, email: "a@example.com", owner: "Ann"}, x)
+
+	err = dec.Decode([]byte("Email=nobody"), x)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "Email", fe.Key)
+	assert.EqualError(t, err, `form: field "Email" (string): invalid email address`)
+
+	err = dec.Decode([]byte("nick=Annie"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, nickname("Annie"), x.nick)
+}
+
+type hintStruct struct {
+	Zip   interface{} `form:"zip,as=string"`
+	Count interface{} `form:"count,as=int64"`
+	Ratio interface{} `form:"ratio,as=float64"`
+	On    interface{} `form:"on,as=bool"`
+	Tags  interface{} `form:"tags,as=string"`
+	Other interface{} `form:"other"`
+}
+
+func TestDecodeTypeHints(t *testing.T) {
+	x := &hintStruct{}
+	err := UnmarshalForm([]byte("zip=02134&count=7&ratio=2&on=yes&tags=1&tags=2&other=02134"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &hintStruct{
+		Zip:   "02134",
+		Count: int64(7),
+		Ratio: float64(2),
+		On:    true,
+		Tags:  []string{"1", "2"},
+		Other: int64(2134),
+	}, x)
+
+	err = UnmarshalForm([]byte("count=x"), x)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "count", fe.Key)
+
+	y := &struct {
+		V interface{} `form:"v,as=uint"`
+	}{}
+	err = UnmarshalForm([]byte("v=1"), y)
+	assert.EqualError(t, err, `form: field "v" (interface {}): unknown type "uint" in as option`)
+}
+
+type queryStruct struct {
+	Search   string   `query:"q" json:"search"`
+	Page     int      `query:"p,omitempty" form:"page"`
+	Tags     []string `query:"tag,split=comma"`
+	Internal string   `query:"-"`
+	PerPage  int
+}
+
+func TestTagName(t *testing.T) {
+	dec := NewDecoder().WithTagName("query")
+	x := &queryStruct{}
+	err := dec.Decode([]byte("q=go&p=2&tag=a,b&internal=x&per_page=10"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &queryStruct{Search: "go", Page: 2, Tags: []string{"a", "b"}, PerPage: 10}, x)
+
+	y := &queryStruct{}
+	err = UnmarshalForm([]byte("q=go&search=yes&page=3&internal=x"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, &queryStruct{Search: "yes", Page: 3, Internal: "x"}, y)
+
+	enc := NewEncoder().WithTagName("query")
+	enc.Casing = SnakeCase
+	data, err := enc.Encode(&queryStruct{Search: "go", Tags: []string{"a", "b"}, Internal: "x"})
+	assert.Nil(t, err)
+	assert.Equal(t, "q=go&tag=a%2Cb&per_page=0", string(data))
+	assert.Equal(t, "", NewEncoder().TagName)
+}
+
+type rawStruct struct {
+	Name string `form:"name"`
+	Body []byte `form:",raw"`
+	Text string `form:"text,raw"`
+}
+
+func TestDecodeRaw(t *testing.T) {
+	data := []byte("name=John+Smith&text=x&z=%41")
+	x := &rawStruct{}
+	err := UnmarshalForm(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, "John Smith", x.Name)
+	assert.Equal(t, data, x.Body)
+	assert.Equal(t, string(data), x.Text)
+	data[0] = 'N'
+	assert.Equal(t, byte('n'), x.Body[0])
+
+	x = &rawStruct{}
+	err = UnmarshalValues(url.Values{"name": {"Ann"}}, x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=Ann", string(x.Body))
+
+	out, err := MarshalForm(&rawStruct{Name: "Ann", Body: []byte("x"), Text: "y"})
+	assert.Nil(t, err)
+	assert.Equal(t, "name=Ann", string(out))
+
+	y := &struct {
+		Raw int `form:",raw"`
+	}{}
+	err = UnmarshalForm([]byte("a=1"), y)
+	assert.True(t, errors.Is(err, ErrUnsupportedType), "err is ErrUnsupportedType")
+}
+
+type restStruct struct {
+	Name  string     `form:"name"`
+	Age   int        `form:"age"`
+	Extra url.Values `form:",rest"`
+}
+
+func TestDecodeRest(t *testing.T) {
+	data := []byte("name=John&age=40&utm_source=mail&tag=a&tag=b&name2[x]=1")
+	x := &restStruct{}
+	dec := NewDecoder()
+	dec.DisallowUnknownFields = true
+	err := dec.Decode(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, "John", x.Name)
+	assert.Equal(t, 40, x.Age)
+	assert.Equal(t, url.Values{"utm_source": {"mail"}, "tag": {"a", "b"}, "name2[x]": {"1"}}, x.Extra)
+
+	out, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&age=40&name2%5Bx%5D=1&tag=a&tag=b&utm_source=mail", string(out))
+
+	y := &struct {
+		Name string              `form:"name"`
+		Rest map[string][]string `form:",rest"`
+	}{}
+	err = UnmarshalForm([]byte("name=Ann"), y)
+	assert.Nil(t, err)
+	assert.Nil(t, y.Rest)
+
+	z := &struct {
+		Rest map[string]string `form:",rest"`
+	}{}
+	err = UnmarshalForm([]byte("a=1"), z)
+	assert.True(t, errors.Is(err, ErrUnsupportedType), "err is ErrUnsupportedType")
+}
+
+type dateRange struct {
+	Start time.Time `form:"start"`
+	End   time.Time `form:"end"`
+}
+
+func TestDecodeValidate(t *testing.T) {
+	calls := 0
+	var got interface{}
+	dec := NewDecoder()
+	dec.Validate = func(obj interface{}) error {
+		calls++
+		got = obj
+		r := obj.(*dateRange)
+		if r.End.Before(r.Start) {
+			return errors.New("end is before start")
+		}
+		return nil
+	}
+	x := &dateRange{}
+	err := dec.Decode([]byte("start=2020-01-01T00:00:00Z&end=2020-02-01T00:00:00Z"), x)
+	assert.Nil(t, err)
+	assert.Same(t, x, got)
+	assert.Equal(t, 1, calls)
+
+	err = dec.Decode([]byte("start=2020-03-01T00:00:00Z&end=2020-02-01T00:00:00Z"), x)
+	assert.EqualError(t, err, "end is before start")
+	assert.Equal(t, 2, calls)
+
+	err = dec.Decode([]byte("start=never"), x)
+	assert.NotNil(t, err)
+	err = dec.DecodeValues(url.Values{"start": {"2020-03-01T00:00:00Z"}, "extra": {"1"}}, x)
+	assert.EqualError(t, err, "end is before start")
+	dec.DisallowUnknownFields = true
+	err = dec.DecodeValues(url.Values{"extra": {"1"}}, x)
+	assert.Equal(t, &UnknownFieldsError{Keys: []string{"extra"}}, err)
+	assert.Equal(t, 3, calls)
+}
+
+type fuzzStruct struct {
+	testStruct
+	User     user          `form:"user"`
+	Users    []user        `form:"users"`
+	Pair     [2]int8       `form:"pair"`
+	Count    *uint16       `form:"count,required"`
+	Any      interface{}   `form:"any"`
+	Data     []byte        `form:"data"`
+	Wait     time.Duration `form:"wait,default=1s"`
+	Number   json.Number   `form:"number"`
+	Tags     []string      `form:"tags,split=comma"`
+	Checked  bool          `form:"checked,presence"`
+	Level    priority      `form:"level"`
+	Optional sql.NullInt64 `form:"optional"`
+	Quoted   interface{}   `form:"quoted,string"`
+	Extra    url.Values    `form:",rest"`
+}
+
+func FuzzUnmarshalForm(f *testing.F) {
+	seeds := []string{
+		"name=John&name=Lennon&birth=1940-10-09T00%3A00%3A00Z&age=81.8&numbers=5&numbers=7",
+		"user[name]=a&user[address][street]=b&users[1][name]=c&users[][city]=d",
+		"pair[0]=1&pair[5]=2&count=0x10&any=1&any=x&data=aGk%3D&wait=2h",
+		"number=1e5&tags=a,b&checked&level=high&optional=&x[y][z]=1",
+		"a[b]=1&a=2&c[d][e]=3&[0]=1&[]=2&%zz",
+		"users[99999999999]=1&numbers[1000000]=1&=&&a[&b]=]",
+		"quoted=%22%22&quoted=1&quoted=%22x%22&quoted=",
+	}
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+	decoders := []*Decoder{NewDecoder(), {
+		CaseInsensitive:       true,
+		CollectErrors:         true,
+		DisallowIndexGaps:     true,
+		DisallowArrayOverflow: true,
+		SkipEmpty:             true,
+		TimeLayouts:           []string{"2006-01-02"},
+	}}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, dec := range decoders {
+			dec.Decode(data, &fuzzStruct{})
+			dec.Decode(data, &map[string]interface{}{})
+			dec.Decode(data, &map[int][]string{})
+			dec.Decode(data, &[]user{})
+			dec.Decode(data, &[3]float32{})
+		}
+	})
+}
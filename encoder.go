@@ -0,0 +1,756 @@
+package form
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Encoder marshals values to form data.  The zero value behaves the same
+// as MarshalForm.
+type Encoder struct {
+	// TagName, if set, is the struct tag that field names and options
+	// are read from instead of the form and json tags.
+	TagName string
+
+	// TimeLayout, if set, is used to format time.Time values in place of
+	// their MarshalText representation, which is RFC 3339 with trailing
+	// zeros trimmed from the fractional seconds.  Use RFC3339NanoFixed
+	// for timestamps that always have nine fractional digits.  The layout
+	// tag option sets it for a single field.
+	TimeLayout string
+
+	// UnixTime writes time.Time values as Unix timestamps instead of
+	// with TimeLayout.  The unix and unixmilli tag options set it for a
+	// single field.
+	UnixTime UnixTime
+
+	// EmptyZeroTime writes zero time.Time values as an empty string
+	// rather than 0001-01-01T00:00:00Z.  Fields tagged omitempty are left
+	// out altogether.
+	EmptyZeroTime bool
+
+	// Casing controls how untagged field names are written.  The default
+	// is LowerCase.
+	Casing Casing
+
+	// BoolStyle controls how bools are written.  The default is
+	// BoolTrueFalse.
+	BoolStyle BoolStyle
+
+	// FloatFormat, if set, controls how floats are written.  It is a
+	// verb from strconv.FormatFloat with an optional precision, like
+	// ".2f" for two fixed decimals or "e" for scientific notation.  The
+	// fmt tag option sets it for a single field.
+	FloatFormat string
+
+	// SortKeys sorts struct fields by key, keeping repeated values for a
+	// key in order.  By default fields are written in declaration order.
+	// Maps are always sorted.
+	SortKeys bool
+
+	// SplitStrings writes string fields as a repeated key with one value
+	// for each part separated by Separator, the inverse of decoding with
+	// JoinValues.
+	SplitStrings bool
+
+	// JoinSlices writes slice and array fields as a single value joined
+	// with Separator instead of as a repeated key.  Fields with a split
+	// tag option are always joined, using the delimiter from the tag.
+	JoinSlices bool
+
+	// Separator is used to split string fields when SplitStrings is set,
+	// and to join slices when JoinSlices is set.  The default is a comma.
+	Separator rune
+
+	// Unescaped lists ASCII characters that are written literally rather
+	// than percent-encoded, for example "[]" to keep nested keys readable.
+	// This can produce query strings that don't parse back to the same
+	// values.
+	Unescaped string
+
+	// SpaceAsPercent20 writes spaces as %20 instead of +, for consumers
+	// that don't treat + as a space.  Decoding accepts either.
+	SpaceAsPercent20 bool
+
+	// RawQuery disables percent-encoding entirely.  Like Unescaped, it
+	// can produce invalid query strings and is meant for debugging and
+	// for endpoints that can't handle encoded keys.
+	RawQuery bool
+
+	// KeepEmptySliceKeys writes keys of url.Values and other maps that
+	// have an empty slice of values as key= instead of leaving them out,
+	// for consumers that treat an empty key differently from a missing
+	// one.
+	KeepEmptySliceKeys bool
+
+	// RawBytes writes []byte values as-is instead of base64 encoding them.
+	RawBytes bool
+
+	// PreferStringer uses the String method of named types that have one
+	// in place of their underlying value, so a named string or int type
+	// is written as its String result.  By default String is only used
+	// for types that can't be written any other way.  MarshalText still
+	// takes precedence.
+	PreferStringer bool
+
+	// GoSyntax writes values that can't otherwise be converted to a
+	// string, such as structs without a String or MarshalText method,
+	// using the %#v format.  By default they cause an error.  This is
+	// meant for debugging.
+	GoSyntax bool
+
+	// Compression compresses the output of EncodeTo.  The default is
+	// NoCompression.
+	Compression Compression
+
+	// DisallowUnsupportedKinds causes Encode to return an error for chan,
+	// func and unsafe.Pointer values.  By default they are skipped.
+	DisallowUnsupportedKinds bool
+}
+
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Clone returns a copy of e that can be changed without affecting e.
+func (e *Encoder) Clone() *Encoder {
+	ec := *e
+	return &ec
+}
+
+// WithTagName returns a copy of e that reads field names and options
+// from the tagName struct tag.
+func (e *Encoder) WithTagName(tagName string) *Encoder {
+	ec := e.Clone()
+	ec.TagName = tagName
+	return ec
+}
+
+func (e *Encoder) Encode(obj interface{}) ([]byte, error) {
+	switch x := obj.(type) {
+	case FormMarshaler:
+		return x.MarshalForm()
+	case url.Values:
+		return []byte(encodePairs(e.valuesPairs(x), e.escapeKey, e.escape)), nil
+	case map[string]string:
+		if !hasConverter(stringType) {
+			pairs := make([]pair, 0, len(x))
+			for k, v := range x {
+				pairs = append(pairs, pair{k, v})
+			}
+			return e.encodeSorted(pairs), nil
+		}
+	case map[string]int:
+		if !hasConverter(stringType, intType) {
+			pairs := make([]pair, 0, len(x))
+			for k, v := range x {
+				pairs = append(pairs, pair{k, strconv.Itoa(v)})
+			}
+			return e.encodeSorted(pairs), nil
+		}
+	case map[string]float64:
+		if e.FloatFormat == "" && !hasConverter(stringType, float64Type) {
+			pairs := make([]pair, 0, len(x))
+			for k, v := range x {
+				pairs = append(pairs, pair{k, strconv.FormatFloat(v, 'f', -1, 64)})
+			}
+			return e.encodeSorted(pairs), nil
+		}
+	case map[string][]string:
+		return e.Encode(url.Values(x))
+	case string:
+		return []byte(x), nil
+	case []byte:
+		return x, nil
+	}
+	pairs, ok, err := e.marshalPairs(obj)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return []byte(encodePairs(pairs, e.escapeKey, e.escape)), nil
+	}
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	s, err := e.asString(rv)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+var (
+	stringType  = reflect.TypeOf("")
+	intType     = reflect.TypeOf(0)
+	float64Type = reflect.TypeOf(0.0)
+)
+
+// hasConverter reports whether any of types has a registered converter,
+// in which case the fast paths in Encode can't be used for them.
+func hasConverter(types ...reflect.Type) bool {
+	for _, t := range types {
+		if _, ok := lookupConverter(t); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeSorted encodes pairs with distinct keys in key order, as
+// Encode does for maps.
+func (e *Encoder) encodeSorted(pairs []pair) []byte {
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].key < pairs[j].key
+	})
+	return []byte(encodePairs(pairs, e.escapeKey, e.escape))
+}
+
+// EncodeValues is like Encode but returns the form data as url.Values.
+func (e *Encoder) EncodeValues(obj interface{}) (url.Values, error) {
+	values := url.Values{}
+	err := e.AddValues(obj, values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// AddValues is like EncodeValues but adds the values to dst, keeping
+// any values it already has.
+func (e *Encoder) AddValues(obj interface{}, dst url.Values) error {
+	pairs, ok, err := e.marshalPairs(obj)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		data, err := e.Encode(obj)
+		if err != nil {
+			return err
+		}
+		pairs, err = parseOrdered(string(data))
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range pairs {
+		dst.Add(p.key, p.value)
+	}
+	return nil
+}
+
+// EncodeTo is like Encode but writes the form data to w.  The key/value
+// pairs are still collected in memory first, but they are escaped and
+// written to w one at a time rather than joined into a single string.
+// The data is compressed if Compression is set.
+func (e *Encoder) EncodeTo(w io.Writer, obj interface{}) error {
+	pairs, ok, err := e.marshalPairs(obj)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		data, err := e.Encode(obj)
+		if err != nil {
+			return err
+		}
+		cw := e.Compression.writer(w)
+		if _, err = cw.Write(data); err != nil {
+			return err
+		}
+		return cw.Close()
+	}
+	cw := e.Compression.writer(w)
+	bw := bufio.NewWriter(cw)
+	err = writePairs(bw, pairs, e.escapeKey, e.escape)
+	if err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// marshalPairs returns the key/value pairs for a struct or map, or false
+// if obj is some other kind of value.
+func (e *Encoder) marshalPairs(obj interface{}) ([]pair, bool, error) {
+	if _, ok := obj.(FormMarshaler); ok {
+		return nil, false, nil
+	}
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		pairs, err := e.marshalStruct(rv, "", []pair{})
+		if err != nil {
+			return nil, false, err
+		}
+		if e.SortKeys {
+			sort.SliceStable(pairs, func(i, j int) bool {
+				return pairs[i].key < pairs[j].key
+			})
+		}
+		return pairs, true, nil
+	case reflect.Map:
+		values := url.Values{}
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := e.asString(iter.Key())
+			if err != nil {
+				return nil, false, err
+			}
+			val := iter.Value()
+			if val.Kind() == reflect.Interface && !val.IsNil() {
+				val = val.Elem()
+			}
+			if val.Kind() == reflect.Ptr {
+				if val.IsNil() {
+					continue
+				}
+				val = val.Elem()
+			}
+			if isUnsupportedKind(val.Type()) {
+				if e.DisallowUnsupportedKinds {
+					return nil, false, unsupportedKindError(key, val.Type())
+				}
+				continue
+			}
+			if isMultiValue(val) {
+				parts, err := e.elements(val)
+				if err != nil {
+					return nil, false, keyError(key, err)
+				}
+				values[key] = append(values[key], parts...)
+			} else {
+				s, err := e.asString(val)
+				if err != nil {
+					return nil, false, keyError(key, err)
+				}
+				values.Set(key, s)
+			}
+		}
+		return e.valuesPairs(values), true, nil
+	case reflect.Slice, reflect.Array:
+		if isBytes(rv.Type()) {
+			return nil, false, nil
+		}
+		pairs, err := e.marshalSlice(rv)
+		return pairs, true, err
+	}
+	return nil, false, nil
+}
+
+// marshalSlice returns the pairs for a slice or array at the top level.
+// Struct elements use indexed keys like [0][name], and other elements
+// are repeated values of the key [].
+func (e *Encoder) marshalSlice(rv reflect.Value) ([]pair, error) {
+	pairs := []pair{}
+	for i := 0; i < rv.Len(); i++ {
+		val := rv.Index(i)
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				continue
+			}
+			val = val.Elem()
+		}
+		var err error
+		if isNestedStruct(val) {
+			pairs, err = e.marshalStruct(val, "["+strconv.Itoa(i)+"]", pairs)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		s, err := e.asString(val)
+		if err != nil {
+			return nil, keyError("[]", err)
+		}
+		pairs = append(pairs, pair{"[]", s})
+	}
+	return pairs, nil
+}
+
+type pair struct {
+	key   string
+	value string
+}
+
+// valuesPairs returns the pairs in values sorted by key, the same order
+// used by url.Values.Encode.
+func (e *Encoder) valuesPairs(values url.Values) []pair {
+	keys := sortedKeys(values)
+	pairs := make([]pair, 0, len(keys))
+	for _, k := range keys {
+		if len(values[k]) == 0 && e.KeepEmptySliceKeys {
+			pairs = append(pairs, pair{k, ""})
+			continue
+		}
+		for _, v := range values[k] {
+			pairs = append(pairs, pair{k, v})
+		}
+	}
+	return pairs
+}
+
+func encodePairs(pairs []pair, escapeKey, escape func(string) string) string {
+	var buf strings.Builder
+	writePairs(&buf, pairs, escapeKey, escape)
+	return buf.String()
+}
+
+// stringWriter is implemented by both strings.Builder and bufio.Writer.
+type stringWriter interface {
+	io.ByteWriter
+	io.StringWriter
+}
+
+func writePairs(w stringWriter, pairs []pair, escapeKey, escape func(string) string) error {
+	for i, p := range pairs {
+		if i > 0 {
+			if err := w.WriteByte('&'); err != nil {
+				return err
+			}
+		}
+		if _, err := w.WriteString(escapeKey(p.key)); err != nil {
+			return err
+		}
+		if err := w.WriteByte('='); err != nil {
+			return err
+		}
+		if _, err := w.WriteString(escape(p.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escape percent-encodes s, except for any characters the encoder has
+// been told to leave alone.
+func (e *Encoder) escape(s string) string {
+	if e.RawQuery {
+		return s
+	}
+	esc := url.QueryEscape(s)
+	if e.SpaceAsPercent20 {
+		esc = strings.ReplaceAll(esc, "+", "%20")
+	}
+	if e.Unescaped == "" {
+		return esc
+	}
+	var buf strings.Builder
+	for i := 0; i < len(esc); i++ {
+		c := esc[i]
+		if c == '+' && strings.IndexByte(e.Unescaped, ' ') >= 0 {
+			buf.WriteByte(' ')
+			continue
+		}
+		if c == '%' && i+2 < len(esc) {
+			b, err := strconv.ParseUint(esc[i+1:i+3], 16, 8)
+			if err == nil && b < utf8.RuneSelf && strings.IndexByte(e.Unescaped, byte(b)) >= 0 {
+				buf.WriteByte(byte(b))
+				i += 2
+				continue
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// escapeKey is like escape, but when brackets are left unescaped it
+// escapes the base and each bracketed segment of a key like a[b][c]
+// separately, so that only the brackets between segments are written
+// literally.
+func (e *Encoder) escapeKey(key string) string {
+	if e.RawQuery || !strings.ContainsAny(e.Unescaped, "[]") {
+		return e.escape(key)
+	}
+	se := *e
+	se.Unescaped = strings.NewReplacer("[", "", "]", "").Replace(e.Unescaped)
+	i := strings.IndexByte(key, '[')
+	if i < 0 || !strings.HasSuffix(key, "]") {
+		return se.escape(key)
+	}
+	var buf strings.Builder
+	buf.WriteString(se.escape(key[:i]))
+	for _, seg := range strings.Split(key[i+1:len(key)-1], "][") {
+		buf.WriteString(e.escape("["))
+		buf.WriteString(se.escape(seg))
+		buf.WriteString(e.escape("]"))
+	}
+	return buf.String()
+}
+
+func (e *Encoder) marshalStruct(rv reflect.Value, prefix string, pairs []pair) ([]pair, error) {
+	for _, f := range cachedFields(rv.Type(), e.TagName).list {
+		if f.raw {
+			continue
+		}
+		val, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			continue
+		}
+		if f.rest {
+			pairs = appendRest(pairs, val, prefix)
+			continue
+		}
+		opts := f.opts
+		tag := f.name
+		if tag == "" {
+			tag = e.Casing.apply(f.goName)
+		}
+		if prefix != "" {
+			tag = prefix + "[" + tag + "]"
+		}
+		if isUnsupportedKind(f.typ) {
+			if e.DisallowUnsupportedKinds {
+				return nil, unsupportedKindError(tag, f.typ)
+			}
+			continue
+		}
+		if opts.Contains("omitempty") && isEmptyValue(val) {
+			continue
+		}
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				continue
+			}
+			val = val.Elem()
+		}
+		if isSQLNull(val.Type()) {
+			if !val.Field(1).Bool() {
+				continue
+			}
+			val = val.Field(0)
+		}
+		fe := e.withFieldOptions(opts)
+		var err error
+		if isNestedStruct(val) {
+			pairs, err = fe.marshalStruct(val, tag, pairs)
+			if err != nil {
+				return nil, err
+			}
+		} else if isMultiValue(val) {
+			delim := splitDelimiter(opts)
+			if delim == "" && fe.JoinSlices {
+				delim = string(separator(fe.Separator))
+			}
+			parts, err := fe.elements(val)
+			if err != nil {
+				return nil, keyError(tag, err)
+			}
+			if delim != "" && len(parts) > 0 {
+				pairs = append(pairs, pair{tag, strings.Join(parts, delim)})
+			} else {
+				for _, part := range parts {
+					pairs = append(pairs, pair{tag, part})
+				}
+			}
+		} else if fe.SplitStrings && val.Kind() == reflect.String {
+			for _, part := range strings.Split(val.String(), string(separator(fe.Separator))) {
+				pairs = append(pairs, pair{tag, part})
+			}
+		} else {
+			s, err := fe.asString(val)
+			if err != nil {
+				return nil, keyError(tag, err)
+			}
+			pairs = append(pairs, pair{tag, s})
+		}
+	}
+	return pairs, nil
+}
+
+// appendRest adds the keys collected by a field with the rest option,
+// sorted by key.
+func appendRest(pairs []pair, val reflect.Value, prefix string) []pair {
+	if val.Kind() != reflect.Map || val.Type().Key().Kind() != reflect.String || val.Type().Elem() != stringsType {
+		return pairs
+	}
+	keys := make([]string, 0, val.Len())
+	iter := val.MapRange()
+	for iter.Next() {
+		keys = append(keys, iter.Key().String())
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vals := val.MapIndex(reflect.ValueOf(k).Convert(val.Type().Key())).Interface().([]string)
+		for _, v := range vals {
+			pairs = append(pairs, pair{joinKey(prefix, k), v})
+		}
+	}
+	return pairs
+}
+
+// elements converts each element of the slice or array val to a string.
+// Pointer elements are dereferenced, and nil ones are left out.
+func (e *Encoder) elements(val reflect.Value) ([]string, error) {
+	parts := make([]string, 0, val.Len())
+	for j := 0; j < val.Len(); j++ {
+		ev := val.Index(j)
+		if ev.Kind() == reflect.Ptr {
+			if ev.IsNil() {
+				continue
+			}
+			ev = ev.Elem()
+		}
+		s, err := e.asString(ev)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, s)
+	}
+	return parts, nil
+}
+
+// isUnsupportedKind reports whether values of type t, or of what t
+// points to, can never be written to a form.
+func isUnsupportedKind(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+func unsupportedKindError(key string, t reflect.Type) error {
+	return &FieldError{Key: key, Type: t, Err: unsupportedf("unsupported kind %s", t.Kind())}
+}
+
+// isMultiValue reports whether val is a slice or array that should be
+// encoded as a repeated key.
+func isMultiValue(val reflect.Value) bool {
+	if c, ok := lookupConverter(val.Type()); ok && c.marshal != nil {
+		return false
+	}
+	switch val.Kind() {
+	case reflect.Slice:
+		return !isBytes(val.Type())
+	case reflect.Array:
+		return true
+	}
+	return false
+}
+
+// isNestedStruct reports whether val is a struct that should be encoded
+// field by field rather than as a single value.
+func isNestedStruct(val reflect.Value) bool {
+	if val.Kind() != reflect.Struct {
+		return false
+	}
+	if c, ok := lookupConverter(val.Type()); ok && c.marshal != nil {
+		return false
+	}
+	if _, ok := textMarshaler(val); ok {
+		return false
+	}
+	if _, ok := binaryMarshaler(val); ok {
+		return false
+	}
+	if _, ok := flagValue(val); ok {
+		return false
+	}
+	_, ok := val.Interface().(fmt.Stringer)
+	return !ok
+}
+
+// withFieldOptions returns an encoder with any options set in a field's
+// tag applied.
+func (e *Encoder) withFieldOptions(opts tagOptions) *Encoder {
+	layout, hasLayout := opts.Get("layout")
+	ffmt, hasFmt := opts.Get("fmt")
+	unix := unixTime(opts, e.UnixTime)
+	if !hasLayout && !hasFmt && unix == e.UnixTime {
+		return e
+	}
+	fe := *e
+	fe.UnixTime = unix
+	if hasLayout {
+		fe.TimeLayout = layout
+	}
+	if hasFmt {
+		fe.FloatFormat = ffmt
+	}
+	return &fe
+}
+
+// asString converts a single value to a string.  It tries, in order, a
+// registered converter, the time options, MarshalText, a flag.Value's
+// String method, String for named types if PreferStringer is set,
+// MarshalBinary, the byte, bool and float options, and then the value's
+// kind, with String as the last resort.
+func (e *Encoder) asString(val reflect.Value) (string, error) {
+	if val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	if c, ok := lookupConverter(val.Type()); ok && c.marshal != nil {
+		return c.marshal(val)
+	}
+	if e.EmptyZeroTime && val.Type() == timeType && val.Interface().(time.Time).IsZero() {
+		return "", nil
+	}
+	if e.UnixTime != UnixNone && val.Type() == timeType {
+		return e.UnixTime.format(val.Interface().(time.Time)), nil
+	}
+	if e.TimeLayout != "" && val.Type() == timeType {
+		return val.Interface().(time.Time).Format(e.TimeLayout), nil
+	}
+	if _, ok := textMarshaler(val); ok {
+		return e.format(val)
+	}
+	if _, ok := flagValue(val); ok {
+		return e.format(val)
+	}
+	if e.PreferStringer && val.Type().PkgPath() != "" {
+		if sval, ok := stringer(val); ok {
+			return sval.String(), nil
+		}
+	}
+	if bval, ok := binaryMarshaler(val); ok {
+		b, err := bval.MarshalBinary()
+		if err != nil {
+			return "", &FieldError{Type: val.Type(), Err: err}
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	if isBytes(val.Type()) {
+		if e.RawBytes {
+			return string(val.Bytes()), nil
+		}
+		return base64.StdEncoding.EncodeToString(val.Bytes()), nil
+	}
+	if val.Kind() == reflect.Bool {
+		return e.BoolStyle.format(val.Bool()), nil
+	}
+	if e.FloatFormat != "" && (val.Kind() == reflect.Float64 || val.Kind() == reflect.Float32) {
+		return formatFloat(val, e.FloatFormat)
+	}
+	return e.format(val)
+}
+
+// format converts val with asString, falling back to Go syntax if
+// GoSyntax is set.
+func (e *Encoder) format(val reflect.Value) (string, error) {
+	if s, ok := asString(val); ok {
+		return s, nil
+	}
+	if e.GoSyntax {
+		return fmt.Sprintf("%#v", val.Interface()), nil
+	}
+	return "", &FieldError{Type: val.Type(), Err: unsupportedf("unsupported type")}
+}
@@ -0,0 +1,700 @@
+package form
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoderTimeLayout(t *testing.T) {
+	x := &testStruct{
+		Name:      []string{"John"},
+		Birthdate: time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC),
+		Age:       81.8,
+	}
+	enc := NewEncoder()
+	enc.TimeLayout = "2006-01-02"
+	data, err := enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&birth=1940-10-09&age=81.8", string(data))
+
+	data, err = NewEncoder().Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&birth=1940-10-09T00%3A00%3A00Z&age=81.8", string(data))
+
+	enc.TimeLayout = RFC3339NanoFixed
+	x.Birthdate = time.Date(1940, time.October, 9, 1, 2, 3, 4500, time.UTC)
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&birth=1940-10-09T01%3A02%3A03.000004500Z&age=81.8", string(data))
+	y := &testStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x.Birthdate, y.Birthdate)
+}
+
+func TestPascalParts(t *testing.T) {
+	tests := map[string][]string{
+		"HTTPServer":      {"http", "server"},
+		"UserID":          {"user", "id"},
+		"OAuthToken":      {"o", "auth", "token"},
+		"FavoriteNumbers": {"favorite", "numbers"},
+		"ID":              {"id"},
+		"X":               {"x"},
+		"ÜberName":        {"über", "name"},
+		"Line1Address":    {"line1", "address"},
+		"AddressLine2":    {"address", "line2"},
+		"HTTP2Server":     {"http2", "server"},
+		"Sha256sum":       {"sha256", "sum"},
+		"V2":              {"v2"},
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, pascalParts(name), name)
+	}
+
+	type acronymStruct struct {
+		HTTPServer string
+		UserID     int
+		OAuthToken string
+	}
+	x := &acronymStruct{}
+	err := UnmarshalForm([]byte("http_server=a&user-id=1&o_auth_token=b"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &acronymStruct{HTTPServer: "a", UserID: 1, OAuthToken: "b"}, x)
+
+	enc := NewEncoder()
+	enc.Casing = SnakeCase
+	data, err := enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "http_server=a&user_id=1&o_auth_token=b", string(data))
+
+	type addressForm struct {
+		Line1Address string
+		AddressLine2 string
+	}
+	y := &addressForm{}
+	err = UnmarshalForm([]byte("line1_address=a&address-line2=b"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, &addressForm{Line1Address: "a", AddressLine2: "b"}, y)
+
+	enc.Casing = KebabCase
+	data, err = enc.Encode(y)
+	assert.Nil(t, err)
+	assert.Equal(t, "line1-address=a&address-line2=b", string(data))
+}
+
+type unicodeStruct struct {
+	ÜberName string
+	É        int
+}
+
+func TestCamelCaseUnicode(t *testing.T) {
+	assert.Equal(t, "überName", camelCase("ÜberName"))
+	assert.Equal(t, "é", camelCase("É"))
+	assert.Equal(t, "", camelCase(""))
+
+	enc := NewEncoder()
+	enc.Casing = CamelCase
+	x := &unicodeStruct{ÜberName: "x", É: 1}
+	data, err := enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "%C3%BCberName=x&%C3%A9=1", string(data))
+
+	y := &unicodeStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+}
+
+type casingStruct struct {
+	FavoriteNumbers []int
+	Name            string
+	Tagged          string `json:"TAGGED"`
+}
+
+func TestEncoderEmptyZeroTime(t *testing.T) {
+	x := &testStruct{Name: []string{"John"}}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&birth=0001-01-01T00%3A00%3A00Z&age=0", string(data))
+
+	enc := NewEncoder()
+	enc.EmptyZeroTime = true
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&birth=&age=0", string(data))
+
+	data, err = enc.Encode(&omitStruct{})
+	assert.Nil(t, err)
+	assert.Equal(t, "score=0", string(data))
+
+	data, err = MarshalForm(&omitStruct{})
+	assert.Nil(t, err)
+	assert.Equal(t, "score=0", string(data))
+}
+
+func TestEncoderCasing(t *testing.T) {
+	x := &casingStruct{FavoriteNumbers: []int{5}, Name: "John", Tagged: "x"}
+	tests := map[Casing]string{
+		LowerCase: "favoritenumbers=5&name=John&TAGGED=x",
+		SnakeCase: "favorite_numbers=5&name=John&TAGGED=x",
+		KebabCase: "favorite-numbers=5&name=John&TAGGED=x",
+		CamelCase: "favoriteNumbers=5&name=John&TAGGED=x",
+	}
+	for casing, expected := range tests {
+		enc := NewEncoder()
+		enc.Casing = casing
+		data, err := enc.Encode(x)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(data))
+
+		y := &casingStruct{}
+		err = UnmarshalForm(data, y)
+		assert.Nil(t, err)
+		assert.Equal(t, x, y)
+	}
+}
+
+type boolStruct struct {
+	Agree bool `json:"agree"`
+	Spam  bool `json:"spam"`
+}
+
+func TestEncoderBoolStyle(t *testing.T) {
+	x := &boolStruct{Agree: true}
+	tests := map[BoolStyle]string{
+		BoolTrueFalse: "agree=true&spam=false",
+		BoolOneZero:   "agree=1&spam=0",
+		BoolYesNo:     "agree=yes&spam=no",
+	}
+	for style, expected := range tests {
+		enc := NewEncoder()
+		enc.BoolStyle = style
+		data, err := enc.Encode(x)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(data))
+
+		y := &boolStruct{Spam: true}
+		err = UnmarshalForm(data, y)
+		assert.Nil(t, err)
+		assert.Equal(t, x, y)
+	}
+
+	y := &boolStruct{}
+	err := UnmarshalForm([]byte("agree=on&spam=off"), y)
+	assert.Nil(t, err)
+	assert.True(t, y.Agree)
+	assert.False(t, y.Spam)
+
+	err = UnmarshalForm([]byte("agree=maybe"), y)
+	assert.NotNil(t, err)
+}
+
+func TestEncoderSortKeys(t *testing.T) {
+	x := &testStruct{
+		Name:            []string{"John", "Lennon"},
+		Birthdate:       time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC),
+		Age:             81.8,
+		FavoriteNumbers: []int{7, 5},
+	}
+	enc := NewEncoder()
+	enc.SortKeys = true
+	data, err := enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "age=81.8&birth=1940-10-09T00%3A00%3A00Z&name=John&name=Lennon&numbers=7&numbers=5", string(data))
+
+	values := url.Values{}
+	err = UnmarshalForm(data, &values)
+	assert.Nil(t, err)
+	assert.Equal(t, values.Encode(), string(data))
+}
+
+type params map[string][]string
+
+func TestEncodeSliceValuedMaps(t *testing.T) {
+	data, err := MarshalForm(map[string][]int{"b": {2, 1}, "a": {3}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=3&b=2&b=1", string(data))
+
+	data, err = MarshalForm(params{"b": {"x", "y"}, "a": {"z"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=z&b=x&b=y", string(data))
+
+	data, err = MarshalForm(map[string]interface{}{"a": []string{"x", "y"}, "b": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=x&a=y&b=1", string(data))
+}
+
+func TestEncoderKeepEmptySliceKeys(t *testing.T) {
+	values := url.Values{"a": {"1"}, "b": {}, "c": nil}
+	data, err := MarshalForm(values)
+	assert.Nil(t, err)
+	assert.Equal(t, "a=1", string(data))
+
+	enc := NewEncoder()
+	enc.KeepEmptySliceKeys = true
+	data, err = enc.Encode(values)
+	assert.Nil(t, err)
+	assert.Equal(t, "a=1&b=&c=", string(data))
+
+	data, err = enc.Encode(map[string][]int{"a": {1}, "b": {}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=1&b=", string(data))
+
+	ev, err := enc.EncodeValues(values)
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{"a": {"1"}, "b": {""}, "c": {""}}, ev)
+}
+
+func TestEncoderSplitStrings(t *testing.T) {
+	x := &multiStringStruct{Tags: "a|b|c"}
+	enc := NewEncoder()
+	enc.SplitStrings = true
+	enc.Separator = '|'
+	data, err := enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "tags=a&tags=b&tags=c", string(data))
+
+	dec := NewDecoder()
+	dec.Separator = '|'
+	y := &multiStringStruct{}
+	err = dec.Decode(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	data, err = NewEncoder().Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "tags=a%7Cb%7Cc", string(data))
+}
+
+func TestEncoderJoinSlices(t *testing.T) {
+	x := &testStruct{Name: []string{"John", "Lennon"}, FavoriteNumbers: []int{5, 7, 9}}
+	enc := NewEncoder()
+	enc.TimeLayout = "2006"
+	enc.JoinSlices = true
+	data, err := enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John%2CLennon&birth=0001&age=0&numbers=5%2C7%2C9", string(data))
+
+	enc.Separator = ';'
+	x.Name = nil
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "birth=0001&age=0&numbers=5%3B7%3B9", string(data))
+
+	enc.Separator = 0
+	data, err = enc.Encode(&splitStruct{Words: []string{"a", "b"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "words=a+b&tags=%7C%7C", string(data))
+}
+
+type unsupportedStruct struct {
+	Name  string        `json:"name"`
+	Point point2        `json:"point"`
+	Any   []interface{} `json:"any"`
+}
+
+type point2 struct {
+	X, Y int
+}
+
+func TestEncoderUnsupported(t *testing.T) {
+	x := &unsupportedStruct{Name: "John", Any: []interface{}{1, "a", nil}}
+	_, err := MarshalForm(map[string]point2{"p": {1, 2}})
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "p", fe.Key)
+
+	m := map[string]interface{}{"x": unsupportedStruct{}}
+	_, err = MarshalForm(m)
+	assert.NotNil(t, err)
+
+	type wrapper struct {
+		Points []point2 `json:"points"`
+	}
+	_, err = MarshalForm(&wrapper{Points: []point2{{1, 2}}})
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, `form: field "points" (form.point2): unsupported type`, err.Error())
+
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&point[x]=0&point[y]=0&any=1&any=a&any=", mustUnescape(t, string(data)))
+
+	enc := NewEncoder()
+	enc.GoSyntax = true
+	data, err = enc.Encode(&wrapper{Points: []point2{{1, 2}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "points=form.point2{X:1, Y:2}", mustUnescape(t, string(data)))
+}
+
+type chanStruct struct {
+	Name     string      `json:"name"`
+	Updates  chan int    `json:"updates"`
+	Callback func()      `json:"callback"`
+	Done     *chan error `json:"done"`
+}
+
+func TestEncoderUnsupportedKinds(t *testing.T) {
+	done := make(chan error)
+	x := &chanStruct{Name: "John", Updates: make(chan int), Done: &done}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John", string(data))
+
+	data, err = MarshalForm(map[string]interface{}{"a": 1, "b": func() {}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=1", string(data))
+
+	enc := NewEncoder()
+	enc.DisallowUnsupportedKinds = true
+	_, err = enc.Encode(x)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: field "updates" (chan int): unsupported kind chan`, err.Error())
+
+	_, err = enc.Encode(map[string]interface{}{"b": func() {}})
+	assert.Equal(t, `form: field "b" (func()): unsupported kind func`, err.Error())
+}
+
+func mustUnescape(t *testing.T, s string) string {
+	u, err := url.QueryUnescape(s)
+	assert.Nil(t, err)
+	return u
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestEncoderEncodeTo(t *testing.T) {
+	objs := []interface{}{
+		&testStruct{Name: []string{"John", "Lennon"}, Age: 81.8, FavoriteNumbers: []int{5, 7}},
+		map[string]interface{}{"a b": []string{"x&y", "z"}, "c": 1},
+		url.Values{"q": {"1", "2"}},
+		&fm{Name: "John"},
+		"a=1",
+	}
+	enc := NewEncoder()
+	for _, obj := range objs {
+		expect, err := MarshalForm(obj)
+		assert.Nil(t, err)
+		var buf bytes.Buffer
+		err = enc.EncodeTo(&buf, obj)
+		assert.Nil(t, err)
+		assert.Equal(t, string(expect), buf.String())
+	}
+
+	err := enc.EncodeTo(failWriter{}, objs[0])
+	assert.NotNil(t, err)
+	err = enc.EncodeTo(&bytes.Buffer{}, map[string]point2{"p": {}})
+	assert.NotNil(t, err)
+
+	var buf bytes.Buffer
+	enc.Compression = Gzip
+	err = enc.EncodeTo(&buf, make(chan int))
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestEncoderEscaping(t *testing.T) {
+	x := &user{Name: "John Smith", Address: address{Street: "1st & Main", City: "NYC"}}
+	data, err := NewEncoder().Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John+Smith&address%5Bstreet%5D=1st+%26+Main&address%5Bcity%5D=NYC", string(data))
+
+	enc := NewEncoder()
+	enc.Unescaped = "[]"
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John+Smith&address[street]=1st+%26+Main&address[city]=NYC", string(data))
+
+	y := &user{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	enc = NewEncoder()
+	enc.RawQuery = true
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John Smith&address[street]=1st & Main&address[city]=NYC", string(data))
+
+	data, err = enc.Encode(url.Values{"a[b]": {"c d"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a[b]=c d", string(data))
+}
+
+func TestEncoderSpaceAsPercent20(t *testing.T) {
+	x := map[string]string{"full name": "John Smith+1"}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "full+name=John+Smith%2B1", string(data))
+
+	enc := NewEncoder()
+	enc.SpaceAsPercent20 = true
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "full%20name=John%20Smith%2B1", string(data))
+	y := map[string]string{}
+	err = UnmarshalForm(data, &y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	enc.Unescaped = " "
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "full name=John Smith%2B1", string(data))
+}
+
+type oddTagStruct struct {
+	Inner struct {
+		Value string `form:"x]&y"`
+	} `form:"a"`
+}
+
+func TestEncoderReservedKeys(t *testing.T) {
+	m := map[string]string{"b&c=d": "1", "e": "2"}
+	data, err := MarshalForm(m)
+	assert.Nil(t, err)
+	assert.Equal(t, "b%26c%3Dd=1&e=2", string(data))
+	y := map[string]string{}
+	err = UnmarshalForm(data, &y)
+	assert.Nil(t, err)
+	assert.Equal(t, m, y)
+
+	data, err = MarshalForm(url.Values{"a[b&c]": {"1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a%5Bb%26c%5D=1", string(data))
+
+	enc := NewEncoder()
+	enc.Unescaped = "[]"
+	data, err = enc.Encode(url.Values{"a[b&c]": {"1"}, "d]": {"2"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "a[b%26c]=1&d%5D=2", string(data))
+
+	x := &oddTagStruct{}
+	x.Inner.Value = "v"
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "a[x%5D%26y]=v", string(data))
+}
+
+type priority int
+
+var priorityNames = []string{"low", "normal", "high"}
+
+func (p priority) MarshalText() ([]byte, error) {
+	if p < 0 || int(p) >= len(priorityNames) {
+		return nil, errors.New("invalid priority")
+	}
+	return []byte(priorityNames[p]), nil
+}
+
+func (p *priority) UnmarshalText(text []byte) error {
+	for i, name := range priorityNames {
+		if name == string(text) {
+			*p = priority(i)
+			return nil
+		}
+	}
+	return errors.New("invalid priority")
+}
+
+type ptrPriority int
+
+func (p *ptrPriority) MarshalText() ([]byte, error) {
+	return priority(*p).MarshalText()
+}
+
+func TestTextMarshalerMapKeys(t *testing.T) {
+	x := map[priority]int{0: 3, 2: 1}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "high=1&low=3", string(data))
+	y := map[priority]int{}
+	err = UnmarshalForm(data, &y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	data, err = MarshalForm(map[ptrPriority]int{1: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "normal=2", string(data))
+
+	data, err = MarshalForm(map[priority]int{5: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "5=1", string(data))
+}
+
+type priceStruct struct {
+	Price float64 `form:"price,fmt=.2f"`
+	Mass  float64 `form:"mass"`
+}
+
+func TestEncoderFloatFormat(t *testing.T) {
+	x := &priceStruct{Price: 3.5, Mass: 0.000123}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "price=3.50&mass=0.000123", string(data))
+
+	enc := NewEncoder()
+	enc.FloatFormat = "e"
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "price=3.50&mass=1.23e-04", string(data))
+
+	enc.FloatFormat = ".1E"
+	data, err = enc.Encode(map[string]float64{"c": 299792458})
+	assert.Nil(t, err)
+	assert.Equal(t, "c=3.0E%2B08", string(data))
+
+	y := &priceStruct{}
+	err = UnmarshalForm([]byte("price=3.50&mass=1.23e-04"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, &priceStruct{Price: 3.5, Mass: 0.000123}, y)
+
+	enc.FloatFormat = "x"
+	_, err = enc.Encode(map[string]float64{"c": 1})
+	assert.EqualError(t, err, `form: field "c" (float64): invalid float format "x"`)
+	enc.FloatFormat = "2f"
+	_, err = enc.Encode(map[string]float64{"c": 1})
+	assert.EqualError(t, err, `form: field "c" (float64): invalid float format "2f"`)
+}
+
+type optionalStruct struct {
+	IDs   []*int       `form:"id"`
+	Times []*time.Time `form:"t"`
+}
+
+func TestEncodePointerElements(t *testing.T) {
+	one, three := 1, 3
+	when := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	x := &optionalStruct{IDs: []*int{&one, nil, &three}, Times: []*time.Time{nil, &when}}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "id=1&id=3&t=2020-01-02T00%3A00%3A00Z", string(data))
+	y := &optionalStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, []*int{&one, &three}, y.IDs)
+	assert.Equal(t, []*time.Time{&when}, y.Times)
+
+	data, err = MarshalForm(map[string][]*int{"id": {nil, &three}})
+	assert.Nil(t, err)
+	assert.Equal(t, "id=3", string(data))
+
+	data, err = MarshalForm(map[string]*int{"a": &one, "b": nil})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=1", string(data))
+	data, err = MarshalForm(map[string]interface{}{"a": &when, "b": (*int)(nil)})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=2020-01-02T00%3A00%3A00Z", string(data))
+}
+
+type currency string
+
+func (c currency) String() string {
+	return strings.ToUpper(string(c))
+}
+
+type weekday int
+
+func (d *weekday) String() string {
+	return time.Weekday(*d).String()
+}
+
+type stringerStruct struct {
+	Currency currency `form:"currency"`
+	Day      weekday  `form:"day"`
+	Price    int      `form:"price"`
+}
+
+func TestEncoderPreferStringer(t *testing.T) {
+	x := stringerStruct{Currency: "usd", Day: 2, Price: 5}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "currency=usd&day=2&price=5", string(data))
+
+	enc := NewEncoder()
+	enc.PreferStringer = true
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "currency=USD&day=Tuesday&price=5", string(data))
+
+	data, err = enc.Encode(map[string]time.Duration{"t": 90 * time.Second})
+	assert.Nil(t, err)
+	assert.Equal(t, "t=1m30s", string(data))
+}
+
+type (
+	reflectStringMap  map[string]string
+	reflectIntMap     map[string]int
+	reflectFloat64Map map[string]float64
+)
+
+func TestEncodeFastPaths(t *testing.T) {
+	sm := map[string]string{"b": "x y", "a": "", "c&d": "1=2"}
+	im := map[string]int{"b": -12, "a": 0, "c": 1 << 40}
+	fm := map[string]float64{"b": -1.5, "a": 1e21, "c": math.NaN(), "d": math.Inf(1), "e": 0.1}
+	check := func(enc *Encoder) {
+		for _, objs := range [][2]interface{}{
+			{sm, reflectStringMap(sm)},
+			{im, reflectIntMap(im)},
+			{fm, reflectFloat64Map(fm)},
+		} {
+			fast, err := enc.Encode(objs[0])
+			assert.Nil(t, err)
+			slow, err := enc.Encode(objs[1])
+			assert.Nil(t, err)
+			assert.Equal(t, string(slow), string(fast))
+		}
+	}
+	check(NewEncoder())
+	check(&Encoder{Unescaped: " &", SpaceAsPercent20: true})
+	check(&Encoder{FloatFormat: ".2f"})
+
+	RegisterConverter(reflect.TypeOf(0), func(v reflect.Value) (string, error) {
+		return strconv.FormatInt(v.Int(), 16), nil
+	}, nil)
+	defer converters.Delete(reflect.TypeOf(0))
+	check(NewEncoder())
+	data, err := MarshalForm(map[string]int{"a": 255})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=ff", string(data))
+}
+
+func benchmarkEncode(b *testing.B, obj interface{}) {
+	enc := NewEncoder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := enc.Encode(obj); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeMaps(b *testing.B) {
+	sm := map[string]string{}
+	im := map[string]int{}
+	fm := map[string]float64{}
+	for i := 0; i < 20; i++ {
+		k := "key" + strconv.Itoa(i)
+		sm[k] = strconv.Itoa(i * 7)
+		im[k] = i * 7
+		fm[k] = float64(i) / 8
+	}
+	b.Run("StringFast", func(b *testing.B) { benchmarkEncode(b, sm) })
+	b.Run("StringReflect", func(b *testing.B) { benchmarkEncode(b, reflectStringMap(sm)) })
+	b.Run("IntFast", func(b *testing.B) { benchmarkEncode(b, im) })
+	b.Run("IntReflect", func(b *testing.B) { benchmarkEncode(b, reflectIntMap(im)) })
+	b.Run("Float64Fast", func(b *testing.B) { benchmarkEncode(b, fm) })
+	b.Run("Float64Reflect", func(b *testing.B) { benchmarkEncode(b, reflectFloat64Map(fm)) })
+}
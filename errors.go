@@ -0,0 +1,138 @@
+package form
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotPointer is returned when decoding into something other than
+	// a non-nil pointer.
+	ErrNotPointer = errors.New("not a pointer")
+
+	// ErrUnsupportedType is matched by errors for types that can't be
+	// encoded or decoded.
+	ErrUnsupportedType = errors.New("form: unsupported type")
+
+	// ErrInvalidQuery is matched by errors for input that isn't a valid
+	// query string.
+	ErrInvalidQuery = errors.New("form: invalid query string")
+
+	// ErrEmpty is returned when decoding empty input with
+	// DisallowEmpty set.
+	ErrEmpty = errors.New("form: empty input")
+
+	// ErrTooLarge is returned by Decoder.DecodeFrom when the input is
+	// longer than MaxBytes.
+	ErrTooLarge = errors.New("form: input too large")
+)
+
+// sentinelError has its own message but matches a sentinel error with
+// errors.Is, while still unwrapping to any underlying error.
+type sentinelError struct {
+	sentinel error
+	msg      string
+	err      error
+}
+
+func (e *sentinelError) Error() string {
+	return e.msg
+}
+
+func (e *sentinelError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.err
+}
+
+func unsupportedf(format string, args ...interface{}) error {
+	return &sentinelError{sentinel: ErrUnsupportedType, msg: fmt.Sprintf(format, args...)}
+}
+
+func invalidQuery(err error) error {
+	return &sentinelError{sentinel: ErrInvalidQuery, msg: "form: invalid query string: " + err.Error(), err: err}
+}
+
+// FieldError describes a form value that couldn't be parsed into its
+// destination.
+type FieldError struct {
+	Key   string
+	Value string
+	Type  reflect.Type
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("form: %s value: %s", e.Type, e.Err)
+	}
+	return fmt.Sprintf("form: field %q (%s): %s", e.Key, e.Type, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// keyError attributes err to key if it is a *FieldError that doesn't
+// already have one.
+func keyError(key string, err error) error {
+	var fe *FieldError
+	if errors.As(err, &fe) && fe.Key == "" {
+		fe.Key = key
+	}
+	return err
+}
+
+// UnknownFieldsError lists the keys that didn't match any struct field
+// when decoding with DisallowUnknownFields.
+type UnknownFieldsError struct {
+	Keys []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	quoted := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		quoted[i] = strconv.Quote(k)
+	}
+	if len(quoted) == 1 {
+		return "unknown form field " + quoted[0]
+	}
+	return "unknown form fields " + strings.Join(quoted, ", ")
+}
+
+// MissingFieldsError lists the required fields that were absent from the
+// input.
+type MissingFieldsError struct {
+	Keys []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	quoted := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		quoted[i] = strconv.Quote(k)
+	}
+	if len(quoted) == 1 {
+		return "missing required form field " + quoted[0]
+	}
+	return "missing required form fields " + strings.Join(quoted, ", ")
+}
+
+// MultiError holds the errors from a decode with CollectErrors set.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e MultiError) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,335 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// field describes a struct field that takes part in encoding, including
+// fields promoted from embedded structs.
+type field struct {
+	name   string
+	goName string
+	tagged bool
+	index  []int
+	typ    reflect.Type
+	opts   tagOptions
+	// raw is set for fields with the raw option, which receive the
+	// undecoded input instead of a key.
+	raw bool
+	// rest is set for fields with the rest option, which collect the
+	// keys that don't match any other field.
+	rest bool
+}
+
+// special reports whether f is a raw or rest field, which don't have
+// keys of their own.
+func (f field) special() bool {
+	return f.raw || f.rest
+}
+
+// key returns the name used to resolve conflicts between fields.
+func (f field) key() string {
+	if f.tagged {
+		return f.name
+	}
+	return f.goName
+}
+
+// structFields is the cached field metadata for a struct type.
+type structFields struct {
+	typ  reflect.Type
+	list []field
+	// keys maps every key accepted when decoding to an index in list.
+	keys map[string]int
+	// keysErr reports two fields that accept the same key.
+	keysErr error
+	// rest is the index in list of the field with the rest option, or
+	// -1 if there isn't one.
+	rest int
+	// setters maps the keys of unexported fields to their setter
+	// methods, for Decoder.UseSetters.
+	setters map[string]setter
+
+	foldOnce sync.Once
+	folded   map[string]int
+	foldErr  error
+}
+
+// foldedKeys returns keys with every key lowercased, or an error if that
+// makes any key ambiguous.
+func (sf *structFields) foldedKeys() (map[string]int, error) {
+	sf.foldOnce.Do(func() {
+		keys := make([]string, 0, len(sf.keys))
+		for k := range sf.keys {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		folded := map[string]int{}
+		for _, k := range keys {
+			i := sf.keys[k]
+			lk := strings.ToLower(k)
+			if j, ok := folded[lk]; ok && j != i {
+				a, b := sf.list[j].goName, sf.list[i].goName
+				sf.foldErr = fmt.Errorf("form: fields %s and %s of %s both match key %q ignoring case", a, b, sf.typ, lk)
+				return
+			}
+			folded[lk] = i
+		}
+		sf.folded = folded
+	})
+	return sf.folded, sf.foldErr
+}
+
+// setter describes an unexported field that is set by calling a method
+// named Set followed by the field's name, like SetName for name.
+type setter struct {
+	method string
+	typ    reflect.Type
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// typeSetters returns the setters for the unexported fields of struct type
+// t, keyed the same way as exported fields.
+func typeSetters(t reflect.Type, tagName string) map[string]setter {
+	setters := map[string]setter{}
+	pt := reflect.PtrTo(t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath == "" || sf.Anonymous {
+			continue
+		}
+		name, _ := parseTag(sf, tagName)
+		if name == "-" {
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(sf.Name)
+		goName := string(unicode.ToUpper(r)) + sf.Name[n:]
+		m, ok := pt.MethodByName("Set" + goName)
+		if !ok || m.Type.NumIn() != 2 || m.Type.In(1).Kind() != reflect.String ||
+			m.Type.NumOut() != 1 || m.Type.Out(0) != errorType {
+			continue
+		}
+		s := setter{method: m.Name, typ: sf.Type}
+		parts := pascalParts(goName)
+		for _, k := range []string{goName, strings.ToLower(goName), camelCase(goName), snakeCase(parts), kebabCase(parts)} {
+			setters[k] = s
+		}
+		if name != "" {
+			setters[name] = s
+			setters[strings.ToLower(name)] = s
+		}
+	}
+	return setters
+}
+
+// fieldCacheKey identifies the fields of a type read with a tag name.
+type fieldCacheKey struct {
+	typ     reflect.Type
+	tagName string
+}
+
+var fieldCache sync.Map // map[fieldCacheKey]*structFields
+
+// cachedFields is like typeFields but also works out the keys accepted
+// for each field, and caches the result so that it is only computed once
+// per type and tag name.  The result must not be modified.
+func cachedFields(t reflect.Type, tagName string) *structFields {
+	ck := fieldCacheKey{t, tagName}
+	if sf, ok := fieldCache.Load(ck); ok {
+		return sf.(*structFields)
+	}
+	fields, keysErr := typeFields(t, tagName)
+	keys := map[string]int{}
+	for i, f := range fields {
+		if f.name != "" && !f.special() {
+			keys[f.name] = i
+		}
+	}
+	// Every field also accepts its Go name in each casing, as long as
+	// no other field claims the same key.  Go names shared by fields at
+	// the same depth are ambiguous, as they are in Go, and aren't used.
+	goNames := map[string]int{}
+	for _, f := range fields {
+		goNames[f.goName]++
+	}
+	for i, f := range fields {
+		if f.special() || goNames[f.goName] > 1 {
+			continue
+		}
+		parts := pascalParts(f.goName)
+		for _, k := range []string{f.goName, strings.ToLower(f.goName), camelCase(f.goName), snakeCase(parts), kebabCase(parts)} {
+			j, ok := keys[k]
+			if !ok {
+				keys[k] = i
+			} else if j != i && keysErr == nil {
+				a, b := fields[j].goName, f.goName
+				keysErr = fmt.Errorf("form: fields %s and %s of %s both match key %q", a, b, t, k)
+			}
+		}
+	}
+	rest := -1
+	for i, f := range fields {
+		if f.rest {
+			rest = i
+		}
+	}
+	sf, _ := fieldCache.LoadOrStore(ck, &structFields{typ: t, list: fields, keys: keys, keysErr: keysErr, rest: rest, setters: typeSetters(t, tagName)})
+	return sf.(*structFields)
+}
+
+// typeFields returns the fields of struct type t that take part in
+// encoding.  Fields of untagged embedded structs are promoted.  A field
+// hides any deeper field with the same Go name, as it does in Go, and
+// then conflicting keys are resolved the way encoding/json does: the
+// shallowest field wins, a tagged field beats an untagged one at the same
+// depth, and otherwise the key is ambiguous and dropped.  Names come from
+// tagName, as described by parseTag.  The error reports tagged fields that
+// were dropped this way, since their tags can't both be honored.
+func typeFields(t reflect.Type, tagName string) ([]field, error) {
+	current := []field{}
+	next := []field{{typ: t}}
+	visited := map[reflect.Type]bool{}
+	// depth is the depth of the shallowest field with each Go name.
+	depth := map[string]int{}
+	fields := []field{}
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if _, ok := depth[sf.Name]; !ok {
+					depth[sf.Name] = len(f.index) + 1
+				}
+				ft := sf.Type
+				if sf.Anonymous {
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if sf.PkgPath != "" && (ft.Kind() != reflect.Struct || sf.Type.Kind() == reflect.Ptr) {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					continue
+				}
+				name, opts := parseTag(sf, tagName)
+				if name == "-" {
+					continue
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, field{goName: sf.Name, index: index, typ: ft})
+					continue
+				}
+				fields = append(fields, field{
+					name:   name,
+					goName: sf.Name,
+					tagged: name != "",
+					index:  index,
+					typ:    sf.Type,
+					opts:   opts,
+					raw:    opts.Contains("raw"),
+					rest:   opts.Contains("rest"),
+				})
+			}
+		}
+	}
+	visible := fields[:0]
+	for _, f := range fields {
+		if len(f.index) == depth[f.goName] {
+			visible = append(visible, f)
+		}
+	}
+	fields = visible
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].key() != x[j].key() {
+			return x[i].key() < x[j].key()
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+	var err error
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].key() != fi.key() {
+				break
+			}
+		}
+		if advance > 1 {
+			fj := fields[i+1]
+			if len(fi.index) == len(fj.index) && fi.tagged == fj.tagged {
+				if fi.tagged && err == nil {
+					err = fmt.Errorf("form: fields %s and %s of %s both match key %q", fi.goName, fj.goName, t, fi.name)
+				}
+				continue
+			}
+		}
+		out = append(out, fi)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return indexLess(out[i].index, out[j].index)
+	})
+	return out, err
+}
+
+func indexLess(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// fieldByIndex returns the field of rv at index, or false if the path
+// runs through a nil embedded pointer.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+// settableFieldByIndex returns the field of rv at index, allocating any
+// nil embedded pointers along the way.
+func settableFieldByIndex(rv reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv
+}
@@ -0,0 +1,95 @@
+package form
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedFieldsConcurrent(t *testing.T) {
+	rt := reflect.TypeOf(user{})
+	results := make([]*structFields, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = cachedFields(rt, "")
+		}(i)
+	}
+	wg.Wait()
+	for _, sf := range results {
+		assert.Same(t, results[0], sf)
+	}
+	fields, err := typeFields(rt, "")
+	assert.Nil(t, err)
+	assert.Equal(t, len(fields), len(results[0].list))
+	assert.Equal(t, 0, results[0].keys["name"])
+}
+
+type collidingStruct struct {
+	UserID  int
+	Account int `json:"user_id"`
+}
+
+type collidingTags struct {
+	A int `form:"x"`
+	B int `form:"x"`
+}
+
+type collidingNames struct {
+	URL string
+	Url string
+}
+
+func TestFieldKeyCollisions(t *testing.T) {
+	err := UnmarshalForm([]byte("user_id=1"), &collidingStruct{})
+	assert.EqualError(t, err, `form: fields Account and UserID of form.collidingStruct both match key "user_id"`)
+	err = UnmarshalForm([]byte("url=x"), &collidingNames{})
+	assert.EqualError(t, err, `form: fields URL and Url of form.collidingNames both match key "url"`)
+	err = UnmarshalForm([]byte("x=1"), &collidingTags{})
+	assert.EqualError(t, err, `form: fields A and B of form.collidingTags both match key "x"`)
+
+	x := &testStruct{}
+	err = UnmarshalForm([]byte("name=John"), x)
+	assert.Nil(t, err)
+	assert.Nil(t, cachedFields(reflect.TypeOf(testStruct{}), "").keysErr)
+}
+
+type ShadowBase struct {
+	ID   int `form:"id"`
+	Name string
+}
+
+type shadowAdmin struct {
+	*ShadowBase
+	Name string `form:"name"`
+}
+
+type ShadowOther struct {
+	ID int `form:"other_id"`
+}
+
+type shadowBoth struct {
+	ShadowBase
+	ShadowOther
+}
+
+func TestFieldShadowing(t *testing.T) {
+	x := &shadowAdmin{}
+	err := UnmarshalForm([]byte("id=1&name=top"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &shadowAdmin{ShadowBase: &ShadowBase{ID: 1}, Name: "top"}, x)
+
+	data, err := MarshalForm(shadowAdmin{ShadowBase: &ShadowBase{ID: 1, Name: "shadow"}, Name: "top"})
+	assert.Nil(t, err)
+	assert.Equal(t, "id=1&name=top", string(data))
+
+	y := &shadowBoth{}
+	err = UnmarshalForm([]byte("id=1&other_id=2"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, y.ShadowBase.ID)
+	assert.Equal(t, 2, y.ShadowOther.ID)
+}
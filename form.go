@@ -2,9 +2,11 @@ package form
 
 import (
 	"encoding"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"net/url"
 	"reflect"
 	"strconv"
@@ -23,94 +25,122 @@ type FormUnmarshaler interface {
 }
 
 func MarshalForm(obj interface{}) ([]byte, error) {
-	switch x := obj.(type) {
-	case FormMarshaler:
-		return x.MarshalForm()
-	case url.Values:
-		return []byte(x.Encode()), nil
-	case map[string]string:
-		values := url.Values{}
-		for k, v := range x {
-			values.Set(k, v)
-		}
-		return []byte(values.Encode()), nil
-	case map[string][]string:
-		return MarshalForm(url.Values(x))
-	case string:
-		return []byte(x), nil
-	case []byte:
-		return x, nil
-	}
-	rv := reflect.ValueOf(obj)
-	if rv.Kind() == reflect.Ptr {
-		rv = rv.Elem()
-	}
-	if rv.Kind() == reflect.Struct {
-		rt := rv.Type()
-		n := rt.NumField()
-		pairs := make([]string, 0, n)
-		for i := 0; i < n; i++ {
-			rf := rt.Field(i)
-			if rf.PkgPath != "" {
-				continue
-			}
-			tag := strings.Split(rf.Tag.Get("json"), ",")[0]
-			if tag == "-" {
-				continue
-			}
-			if tag == "" {
-				tag = strings.ToLower(rf.Name)
-			}
-			val := rv.Field(i)
-			if val.Kind() == reflect.Ptr {
-				if val.IsNil() {
-					continue
-				}
-				val = val.Elem()
-			}
-			if val.Kind() == reflect.Slice {
-				for j := 0; j < val.Len(); j++ {
-					pair := fmt.Sprintf("%s=%s", url.QueryEscape(tag), url.QueryEscape(asString(val.Index(j))))
-					pairs = append(pairs, pair)
-				}
-			} else {
-				pair := fmt.Sprintf("%s=%s", url.QueryEscape(tag), url.QueryEscape(asString(val)))
-				pairs = append(pairs, pair)
-			}
+	return NewEncoder().Encode(obj)
+}
+
+// MarshalFormValues is like MarshalForm but returns url.Values so the
+// result can be modified before it is encoded.
+func MarshalFormValues(obj interface{}) (url.Values, error) {
+	return NewEncoder().EncodeValues(obj)
+}
+
+// MarshalToValues is like MarshalFormValues but adds the values to dst,
+// keeping any values it already has.
+func MarshalToValues(obj interface{}, dst url.Values) error {
+	return NewEncoder().AddValues(obj, dst)
+}
+
+type tagOptions []string
+
+func (opts tagOptions) Contains(name string) bool {
+	for _, opt := range opts {
+		if opt == name {
+			return true
 		}
-		return []byte(strings.Join(pairs, "&")), nil
 	}
-	if rv.Kind() == reflect.Map {
-		values := url.Values{}
-		iter := rv.MapRange()
-		for iter.Next() {
-			values.Set(asString(iter.Key()), asString(iter.Value()))
+	return false
+}
+
+func (opts tagOptions) Get(name string) (string, bool) {
+	for _, opt := range opts {
+		if strings.HasPrefix(opt, name+"=") {
+			return opt[len(name)+1:], true
 		}
-		return []byte(values.Encode()), nil
 	}
-	return []byte(asString(rv)), nil
+	return "", false
+}
+
+// parseTag returns the form key and options for a struct field.  The
+// name comes from the form tag if there is one, otherwise from the json
+// tag; options from both tags apply.  If tagName is set, only that tag
+// is read.
+func parseTag(rf reflect.StructField, tagName string) (string, tagOptions) {
+	if tagName != "" {
+		parts := strings.Split(rf.Tag.Get(tagName), ",")
+		return parts[0], tagOptions(parts[1:])
+	}
+	jsonParts := strings.Split(rf.Tag.Get("json"), ",")
+	tag, ok := rf.Tag.Lookup("form")
+	if !ok {
+		return jsonParts[0], tagOptions(jsonParts[1:])
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" && jsonParts[0] != "-" {
+		name = jsonParts[0]
+	}
+	opts := append(tagOptions(parts[1:]), jsonParts[1:]...)
+	return name, opts
 }
 
+type zeroer interface {
+	IsZero() bool
+}
+
+func isEmptyValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return val.Len() == 0
+	case reflect.Bool:
+		return !val.Bool()
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return val.Int() == 0
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		return val.Uint() == 0
+	case reflect.Float64, reflect.Float32:
+		return val.Float() == 0
+	case reflect.Complex128, reflect.Complex64:
+		return val.Complex() == 0
+	case reflect.Interface, reflect.Ptr:
+		return val.IsNil()
+	case reflect.Struct:
+		z, ok := val.Interface().(zeroer)
+		return ok && z.IsZero()
+	}
+	return false
+}
+
+// pascalParts splits a PascalCase name into lowercase words.  A run of
+// capitals is kept together as an acronym, so HTTPServer is http, server,
+// and digits end the word they follow, so Line1Address is line1, address.
 func pascalParts(s string) []string {
+	runes := []rune(s)
 	parts := []string{}
 	start := 0
-	n := len(s)
-	i := 0
-	bs := []byte(s)
-	for i < n {
-		r, j := utf8.DecodeRune(bs[i:])
-		if i > 0 && unicode.IsUpper(r) {
-			parts = append(parts, strings.ToLower(s[start:i]))
+	for i := 1; i < len(runes); i++ {
+		split := false
+		if unicode.IsDigit(runes[i-1]) {
+			split = unicode.IsLetter(runes[i])
+		} else if unicode.IsUpper(runes[i]) {
+			prevUpper := unicode.IsUpper(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			split = !prevUpper || nextLower
+		}
+		if split {
+			parts = append(parts, strings.ToLower(string(runes[start:i])))
 			start = i
 		}
-		i += j
 	}
-	parts = append(parts, s[start:n])
+	parts = append(parts, strings.ToLower(string(runes[start:])))
 	return parts
 }
 
 func camelCase(s string) string {
-	return strings.ToLower(s[:1]) + s[1:]
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[n:]
 }
 
 func snakeCase(parts []string) string {
@@ -121,114 +151,336 @@ func kebabCase(parts []string) string {
 	return strings.Join(parts, "-")
 }
 
-func UnmarshalForm(data []byte, obj interface{}) error {
-	switch tobj := obj.(type) {
-	case FormUnmarshaler:
-		return tobj.UnmarshalForm(data)
-	case *url.Values:
-		query, err := url.ParseQuery(string(data))
-		if err != nil {
-			return err
+// BoolStyle selects how bool values are written.
+type BoolStyle int
+
+const (
+	BoolTrueFalse BoolStyle = iota // true, false
+	BoolOneZero                    // 1, 0
+	BoolYesNo                      // yes, no
+)
+
+func (style BoolStyle) format(b bool) string {
+	switch style {
+	case BoolOneZero:
+		if b {
+			return "1"
 		}
-		*tobj = query
-		return nil
+		return "0"
+	case BoolYesNo:
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	return strconv.FormatBool(b)
+}
+
+// formatFloat formats val using a float format like ".2f" or "e".
+func formatFloat(val reflect.Value, format string) (string, error) {
+	prec := -1
+	verb := format[len(format)-1]
+	if len(format) > 1 {
+		p, err := strconv.Atoi(strings.TrimPrefix(format[:len(format)-1], "."))
+		if err != nil || format[0] != '.' || p < 0 {
+			verb = 0
+		}
+		prec = p
+	}
+	switch verb {
+	case 'f', 'e', 'E', 'g', 'G':
+		return strconv.FormatFloat(val.Float(), verb, prec, val.Type().Bits()), nil
+	}
+	return "", &FieldError{Type: val.Type(), Err: fmt.Errorf("invalid float format %q", format)}
+}
+
+// parseBool accepts yes/no and on/off in addition to everything
+// strconv.ParseBool accepts.
+func parseBool(val string) (bool, error) {
+	switch strings.ToLower(val) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
 	}
-	rv := reflect.ValueOf(obj)
-	if rv.Kind() != reflect.Ptr {
-		return errors.New("not a pointer")
+	return strconv.ParseBool(val)
+}
+
+// UnixTime selects whether time.Time values are written as Unix
+// timestamps.
+type UnixTime int
+
+const (
+	UnixNone    UnixTime = iota // use the time layout
+	UnixSeconds                 // seconds since the epoch
+	UnixMillis                  // milliseconds since the epoch
+)
+
+// unixTime returns the UnixTime named by a unix or unixmilli tag option,
+// or def if there isn't one.
+func unixTime(opts tagOptions, def UnixTime) UnixTime {
+	if opts.Contains("unixmilli") {
+		return UnixMillis
 	}
-	query, err := url.ParseQuery(string(data))
+	if opts.Contains("unix") {
+		return UnixSeconds
+	}
+	return def
+}
+
+func (u UnixTime) format(t time.Time) string {
+	if u == UnixMillis {
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func (u UnixTime) parse(val string, loc *time.Location) (time.Time, error) {
+	i, err := strconv.ParseInt(val, 10, 64)
 	if err != nil {
-		return err
+		return time.Time{}, fmt.Errorf("can't parse (%s) as a unix time", val)
 	}
-	rv = rv.Elem()
-	rt := rv.Type()
-	switch rv.Kind() {
-	case reflect.Struct:
-		keys := map[string]int{}
-		n := rt.NumField()
-		for i := 0; i < n; i++ {
-			rf := rt.Field(i)
-			if rf.PkgPath != "" {
-				continue
-			}
-			keys[rf.Name] = i
-			keys[strings.ToLower(rf.Name)] = i
-			keys[camelCase(rf.Name)] = i
-			parts := pascalParts(rf.Name)
-			keys[snakeCase(parts)] = i
-			keys[kebabCase(parts)] = i
-		}
-		for i := 0; i < n; i++ {
-			rf := rt.Field(i)
-			if rf.PkgPath != "" {
-				continue
-			}
-			tag := strings.Split(rf.Tag.Get("json"), ",")[0]
-			if tag != "" {
-				keys[tag] = i
-			}
-		}
-		for k, vals := range query {
-			i, ok := keys[k]
-			if !ok {
-				continue
-			}
-			v := reflect.New(rt.Field(i).Type)
-			err := fromStrings(vals, v.Interface())
-			if err != nil {
-				return err
-			}
-			rv.Field(i).Set(v.Elem())
-		}
-	case reflect.Map:
-		for key, vals := range query {
-			kv := reflect.New(rt.Key())
-			err := fromString(key, kv.Interface())
-			if err != nil {
-				return err
-			}
-			pv := reflect.New(rt.Elem())
-			err = fromStrings(vals, pv.Interface())
-			if err != nil {
-				return err
-			}
-			rv.SetMapIndex(kv.Elem(), pv.Elem())
+	if loc == nil {
+		loc = time.UTC
+	}
+	if u == UnixMillis {
+		return time.UnixMilli(i).In(loc), nil
+	}
+	return time.Unix(i, 0).In(loc), nil
+}
+
+// MultiString selects how repeated values are stored in a string field.
+type MultiString int
+
+const (
+	JoinValues MultiString = iota // join the values with a separator
+	FirstValue                    // keep the first value
+	LastValue                     // keep the last value
+)
+
+// splitDelimiter returns the delimiter named by a split=comma, split=space
+// or split=pipe tag option, or "" if there isn't one.  Any other value is
+// used as the delimiter itself.
+func splitDelimiter(opts tagOptions) string {
+	name, _ := opts.Get("split")
+	switch name {
+	case "comma":
+		return ","
+	case "space":
+		return " "
+	case "pipe":
+		return "|"
+	}
+	return name
+}
+
+// splitValues breaks each of vals on the split delimiter, if any.
+func (d *Decoder) splitValues(vals []string) []string {
+	if d.split == "" {
+		return vals
+	}
+	parts := []string{}
+	for _, v := range vals {
+		if v != "" {
+			parts = append(parts, strings.Split(v, d.split)...)
 		}
-	default:
-		return fmt.Errorf("can't unmarshal to %T", obj)
 	}
-	return nil
+	return parts
+}
+
+func separator(sep rune) rune {
+	if sep == 0 {
+		return ','
+	}
+	return sep
+}
+
+// Casing selects how untagged field names are converted to form keys.
+type Casing int
+
+const (
+	LowerCase Casing = iota // favoritenumbers
+	SnakeCase               // favorite_numbers
+	KebabCase               // favorite-numbers
+	CamelCase               // favoriteNumbers
+)
+
+func (c Casing) apply(name string) string {
+	switch c {
+	case SnakeCase:
+		return snakeCase(pascalParts(name))
+	case KebabCase:
+		return kebabCase(pascalParts(name))
+	case CamelCase:
+		return camelCase(name)
+	}
+	return strings.ToLower(name)
+}
+
+func UnmarshalForm(data []byte, obj interface{}) error {
+	return NewDecoder().Decode(data, obj)
+}
+
+// UnmarshalValues is like UnmarshalForm but takes form data that has
+// already been parsed.
+func UnmarshalValues(values url.Values, obj interface{}) error {
+	return NewDecoder().DecodeValues(values, obj)
+}
+
+// addressable returns val, or a copy of it that can be addressed if val
+// can't be, like a map key, so that methods with pointer receivers can be
+// called on it.
+func addressable(val reflect.Value) reflect.Value {
+	if val.CanAddr() {
+		return val
+	}
+	pv := reflect.New(val.Type())
+	pv.Elem().Set(val)
+	return pv.Elem()
+}
+
+// textMarshaler returns val as an encoding.TextMarshaler, taking its
+// address if MarshalText has a pointer receiver.
+func textMarshaler(val reflect.Value) (encoding.TextMarshaler, bool) {
+	tval, ok := val.Interface().(encoding.TextMarshaler)
+	if !ok && reflect.PtrTo(val.Type()).Implements(textMarshalerType) {
+		tval, ok = addressable(val).Addr().Interface().(encoding.TextMarshaler)
+	}
+	return tval, ok
+}
+
+// binaryMarshaler is like textMarshaler for encoding.BinaryMarshaler.
+func binaryMarshaler(val reflect.Value) (encoding.BinaryMarshaler, bool) {
+	bval, ok := val.Interface().(encoding.BinaryMarshaler)
+	if !ok && reflect.PtrTo(val.Type()).Implements(binaryMarshalerType) {
+		bval, ok = addressable(val).Addr().Interface().(encoding.BinaryMarshaler)
+	}
+	return bval, ok
+}
+
+// flagValue is like textMarshaler for flag.Value.
+func flagValue(val reflect.Value) (flag.Value, bool) {
+	fval, ok := val.Interface().(flag.Value)
+	if !ok && reflect.PtrTo(val.Type()).Implements(flagValueType) {
+		fval, ok = addressable(val).Addr().Interface().(flag.Value)
+	}
+	return fval, ok
+}
+
+// stringer is like textMarshaler for fmt.Stringer.
+func stringer(val reflect.Value) (fmt.Stringer, bool) {
+	sval, ok := val.Interface().(fmt.Stringer)
+	if !ok && reflect.PtrTo(val.Type()).Implements(stringerType) {
+		sval, ok = addressable(val).Addr().Interface().(fmt.Stringer)
+	}
+	return sval, ok
 }
 
-func asString(val reflect.Value) string {
+// asString converts a single value to a string, or returns false if it
+// doesn't know how.
+func asString(val reflect.Value) (string, bool) {
+	tval, ok := textMarshaler(val)
+	if ok {
+		text, err := tval.MarshalText()
+		if err == nil {
+			return string(text), true
+		}
+	}
+	if fval, ok := flagValue(val); ok {
+		return fval.String(), true
+	}
 	switch val.Kind() {
 	case reflect.String:
-		return val.String()
+		return val.String(), true
 	case reflect.Bool:
-		return strconv.FormatBool(val.Bool())
+		return strconv.FormatBool(val.Bool()), true
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-		return strconv.FormatInt(val.Int(), 10)
+		return strconv.FormatInt(val.Int(), 10), true
 	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
-		return strconv.FormatUint(val.Uint(), 10)
+		return strconv.FormatUint(val.Uint(), 10), true
 	case reflect.Float64, reflect.Float32:
-		return strconv.FormatFloat(val.Float(), 'f', -1, 64)
+		return strconv.FormatFloat(val.Float(), 'f', -1, 64), true
+	case reflect.Complex128, reflect.Complex64:
+		return strconv.FormatComplex(val.Complex(), 'f', -1, val.Type().Bits()), true
+	case reflect.Interface:
+		if val.IsNil() {
+			return "", true
+		}
 	}
-	ival := val.Interface()
-	tval, ok := ival.(encoding.TextMarshaler)
+	sval, ok := val.Interface().(fmt.Stringer)
 	if ok {
-		text, err := tval.MarshalText()
-		if err == nil {
-			return string(text)
+		return sval.String(), true
+	}
+	return "", false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+var flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+var durationType = reflect.TypeOf(time.Duration(0))
+var numberType = reflect.TypeOf(json.Number(""))
+
+// isValidNumber reports whether s is a number in JSON syntax.
+func isValidNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
 		}
 	}
-	sval, ok := ival.(fmt.Stringer)
-	if ok {
-		return sval.String()
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	default:
+		return false
+	}
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
 	}
-	return fmt.Sprintf("%#v", ival)
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+	return s == ""
 }
 
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// isSQLNull reports whether t is one of the database/sql Null types, like
+// sql.NullString, which wrap a value and a Valid flag.
+func isSQLNull(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.PkgPath() == "database/sql" &&
+		strings.HasPrefix(t.Name(), "Null") && t.NumField() == 2 &&
+		t.Field(1).Name == "Valid" && t.Field(1).Type.Kind() == reflect.Bool
+}
+
+// RFC3339NanoFixed is like time.RFC3339Nano but keeps trailing zeros, so
+// that every timestamp has nine fractional digits.
+const RFC3339NanoFixed = "2006-01-02T15:04:05.000000000Z07:00"
+
 var layouts = []string{
 	time.RFC3339Nano,
 	time.RFC3339,
@@ -241,19 +493,158 @@ var layouts = []string{
 	"2006-01-02",
 }
 
-func fromString(val string, obj interface{}) error {
+func (d *Decoder) parseTime(val string, t *time.Time) error {
+	tlayouts := d.TimeLayouts
+	if len(tlayouts) == 0 {
+		tlayouts = layouts
+	}
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range tlayouts {
+		pt, err := time.ParseInLocation(layout, val, loc)
+		if err == nil {
+			*t = pt
+			return nil
+		}
+	}
+	return fmt.Errorf("can't parse (%s) as a time", val)
+}
+
+func (d *Decoder) fromString(val string, obj interface{}) error {
+	err := d.parseString(val, obj)
+	if err == nil {
+		return nil
+	}
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		return err
+	}
+	return &FieldError{Value: val, Type: reflect.TypeOf(obj).Elem(), Err: err}
+}
+
+// intBase returns the base to parse val with: 0, so that Go literal
+// syntax like 0x1f and 1_000 is accepted, unless DecimalOnly is set.
+// Leading zeros are still read as decimal rather than octal.
+func (d *Decoder) intBase(val string) int {
+	if d.DecimalOnly {
+		return 10
+	}
+	s := strings.TrimLeft(val, "+-")
+	if len(s) > 1 && s[0] == '0' && !strings.ContainsAny(s[1:2], "xXoObB_") {
+		return 10
+	}
+	return 0
+}
+
+// skipEmpty reports whether SkipEmpty applies to val for type t.
+func (d *Decoder) skipEmpty(val string, t reflect.Type) bool {
+	return val == "" && d.SkipEmpty && (t.Kind() != reflect.String || t == numberType)
+}
+
+func (d *Decoder) parseString(val string, obj interface{}) error {
+	if d.quoted && strings.HasPrefix(val, `"`) {
+		uq, err := strconv.Unquote(val)
+		if err != nil {
+			return err
+		}
+		val = uq
+	}
+	rv := reflect.ValueOf(obj).Elem()
+	if d.skipEmpty(val, rv.Type()) {
+		return nil
+	}
+	if c, ok := lookupConverter(rv.Type()); ok && c.unmarshal != nil {
+		return c.unmarshal(val, rv)
+	}
+	tptr, ok := obj.(*time.Time)
+	if ok && d.UnixTime != UnixNone {
+		t, err := d.UnixTime.parse(val, d.Location)
+		if err != nil {
+			return err
+		}
+		*tptr = t
+		return nil
+	}
+	if ok && (len(d.TimeLayouts) > 0 || d.Location != nil) {
+		return d.parseTime(val, tptr)
+	}
 	tum, ok := obj.(encoding.TextUnmarshaler)
 	if ok {
 		return tum.UnmarshalText([]byte(val))
 	}
-	bytesptr, ok := obj.(*[]byte)
+	bum, ok := obj.(encoding.BinaryUnmarshaler)
 	if ok {
-		*bytesptr = []byte(val)
+		b, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		return bum.UnmarshalBinary(b)
+	}
+	fv, ok := obj.(flag.Value)
+	if ok {
+		return fv.Set(val)
+	}
+	if rv.Kind() == reflect.Ptr {
+		pv := reflect.New(rv.Type().Elem())
+		err := d.parseString(val, pv.Interface())
+		if err != nil {
+			return err
+		}
+		rv.Set(pv)
 		return nil
 	}
-	rv := reflect.ValueOf(obj).Elem()
+	if isSQLNull(rv.Type()) {
+		if val == "" && rv.Field(0).Kind() != reflect.String {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		err := d.parseString(val, rv.Field(0).Addr().Interface())
+		if err != nil {
+			return err
+		}
+		rv.Field(1).SetBool(true)
+		return nil
+	}
+	if isBytes(rv.Type()) {
+		if d.RawBytes {
+			rv.SetBytes([]byte(val))
+			return nil
+		}
+		b, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			return err
+		}
+		rv.SetBytes(b)
+		return nil
+	}
+	if d.DecimalComma && isFloatValue(rv) {
+		var err error
+		val, err = decimalComma(val)
+		if err != nil {
+			return err
+		}
+	}
+	if rv.Type() == numberType {
+		if !isValidNumber(val) {
+			return fmt.Errorf("invalid number %q", val)
+		}
+		rv.SetString(val)
+		return nil
+	}
+	if rv.Type() == durationType {
+		dur, err := time.ParseDuration(val)
+		if err == nil {
+			rv.SetInt(int64(dur))
+			return nil
+		}
+	}
 	switch rv.Kind() {
 	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			break
+		}
 		i, err := strconv.ParseInt(val, 10, 64)
 		if err == nil {
 			rv.Set(reflect.ValueOf(i))
@@ -274,12 +665,11 @@ func fromString(val string, obj interface{}) error {
 			rv.Set(reflect.ValueOf(dur))
 			return nil
 		}
-		for _, layout := range layouts {
-			t, err := time.ParseInLocation(layout, val, time.UTC)
-			if err == nil {
-				rv.Set(reflect.ValueOf(t))
-				return nil
-			}
+		var t time.Time
+		err = d.parseTime(val, &t)
+		if err == nil {
+			rv.Set(reflect.ValueOf(t))
+			return nil
 		}
 		rv.Set(reflect.ValueOf(val))
 		return nil
@@ -287,85 +677,219 @@ func fromString(val string, obj interface{}) error {
 		rv.SetString(val)
 		return nil
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-		i, err := strconv.ParseInt(val, 10, 64)
+		i, err := strconv.ParseInt(val, d.intBase(val), rv.Type().Bits())
 		if err != nil {
 			return err
 		}
 		rv.SetInt(i)
 		return nil
 	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
-		u, err := strconv.ParseUint(val, 10, 64)
+		u, err := strconv.ParseUint(val, d.intBase(val), rv.Type().Bits())
 		if err != nil {
 			return err
 		}
 		rv.SetUint(u)
 		return nil
 	case reflect.Float64, reflect.Float32:
-		f, err := strconv.ParseFloat(val, 64)
+		f, err := strconv.ParseFloat(val, rv.Type().Bits())
 		if err != nil {
 			return err
 		}
 		rv.SetFloat(f)
 		return nil
+	case reflect.Complex128, reflect.Complex64:
+		c, err := strconv.ParseComplex(val, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetComplex(c)
+		return nil
 	case reflect.Bool:
-		b, err := strconv.ParseBool(val)
+		b, err := parseBool(val)
 		if err != nil {
 			return err
 		}
 		rv.SetBool(b)
 		return nil
 	}
-	log.Panicf("can't parse (%s) into %T (%s)", val, obj, rv.Kind())
-	return fmt.Errorf("can't parse (%s) into %T (%s)", val, obj, rv.Kind())
+	return unsupportedf("can't parse (%s) into %T (%s)", val, obj, rv.Kind())
 }
 
-func fromStrings(vals []string, obj interface{}) error {
-	rv := reflect.ValueOf(obj).Elem()
+// inferSlice decodes vals into the interface rv as a slice.  If every
+// value infers the same type the slice has that element type, otherwise
+// its elements have rv's interface type.
+func (d *Decoder) inferSlice(vals []string, rv reflect.Value) error {
+	elems := make([]reflect.Value, 0, len(vals))
+	mixed := false
+	for _, v := range vals {
+		if err := d.canceled(); err != nil {
+			return err
+		}
+		iv := reflect.New(rv.Type())
+		err := d.fromString(v, iv.Interface())
+		if err != nil {
+			return err
+		}
+		// Values skipped by SkipEmpty leave iv unset.
+		if iv.Elem().IsNil() {
+			continue
+		}
+		ev := iv.Elem().Elem()
+		if len(elems) > 0 && ev.Type() != elems[0].Type() {
+			mixed = true
+		}
+		elems = append(elems, ev)
+	}
+	if len(elems) == 0 && len(vals) > 0 {
+		return nil
+	}
+	et := rv.Type()
+	if len(elems) > 0 && !mixed {
+		et = elems[0].Type()
+	}
+	sv := reflect.MakeSlice(reflect.SliceOf(et), len(elems), len(elems))
+	for i, ev := range elems {
+		sv.Index(i).Set(ev)
+	}
+	rv.Set(sv)
+	return nil
+}
+
+// decimalComma rewrites a number like 1.234,56 as 1234.56.  Periods must
+// group the digits before the comma in threes, so that a value like 1.5
+// is an error rather than being read as 15.
+func decimalComma(val string) (string, error) {
+	mant, exp := val, ""
+	if i := strings.IndexAny(val, "eE"); i >= 0 {
+		mant, exp = val[:i], val[i:]
+	}
+	whole, frac, hasFrac := strings.Cut(mant, ",")
+	if strings.Contains(frac, ".") {
+		return "", fmt.Errorf("invalid number %q", val)
+	}
+	if strings.Contains(whole, ".") {
+		groups := strings.Split(strings.TrimLeft(whole, "+-"), ".")
+		for i, g := range groups {
+			if len(g) != 3 && (i > 0 || len(g) == 0 || len(g) > 3) {
+				return "", fmt.Errorf("invalid number %q", val)
+			}
+		}
+		whole = strings.ReplaceAll(whole, ".", "")
+	}
+	if hasFrac {
+		return whole + "." + frac + exp, nil
+	}
+	return whole + exp, nil
+}
+
+// isFloatValue reports whether rv is a float or a json.Number, the values
+// that DecimalComma applies to.
+func isFloatValue(rv reflect.Value) bool {
 	switch rv.Kind() {
-	case reflect.Interface:
-		if len(vals) == 1 {
-			pv := reflect.New(rv.Type())
-			err := fromString(vals[0], pv.Interface())
+	case reflect.Float32, reflect.Float64:
+		return true
+	}
+	return rv.Type() == numberType
+}
+
+// isFlagValue reports whether obj is a flag.Value that doesn't also
+// implement one of the encoding unmarshaler interfaces, which take
+// precedence.  Its Set method is called once for each value.
+func isFlagValue(obj interface{}) bool {
+	if _, ok := obj.(flag.Value); !ok {
+		return false
+	}
+	_, tok := obj.(encoding.TextUnmarshaler)
+	_, bok := obj.(encoding.BinaryUnmarshaler)
+	return !tok && !bok
+}
+
+// hintTypes are the types that can be named by the as tag option.
+var hintTypes = map[string]reflect.Type{
+	"string":  reflect.TypeOf(""),
+	"int64":   reflect.TypeOf(int64(0)),
+	"float64": reflect.TypeOf(float64(0)),
+	"bool":    reflect.TypeOf(false),
+}
+
+// decodeAs decodes vals into the interface rv as the type named by the
+// as option, or a slice of it if there is more than one value.
+func (d *Decoder) decodeAs(vals []string, rv reflect.Value) error {
+	t, ok := hintTypes[d.as]
+	if !ok {
+		return &FieldError{Type: rv.Type(), Err: fmt.Errorf("unknown type %q in as option", d.as)}
+	}
+	if len(vals) != 1 {
+		t = reflect.SliceOf(t)
+	}
+	if !t.AssignableTo(rv.Type()) {
+		return unsupportedf("can't decode %s into %s", t, rv.Type())
+	}
+	pv := reflect.New(t)
+	err := d.fromStrings(vals, pv.Interface())
+	if err != nil {
+		return err
+	}
+	rv.Set(pv.Elem())
+	return nil
+}
+
+func (d *Decoder) fromStrings(vals []string, obj interface{}) error {
+	if isFlagValue(obj) {
+		for _, v := range vals {
+			err := d.fromString(v, obj)
 			if err != nil {
 				return err
 			}
-			rv.Set(pv.Elem())
-		} else {
-			pv := reflect.MakeSlice(reflect.SliceOf(rv.Type()), len(vals), len(vals))
-			var stype reflect.Type
-			stypes := 0
-			for i, v := range vals {
-				iv := reflect.New(rv.Type())
-				err := fromString(v, iv.Interface())
-				if err != nil {
-					return err
-				}
-				if i == 0 {
-					stype = reflect.ValueOf(iv.Elem().Interface()).Type()
-					stypes += 1
-				} else if stypes == 1 {
-					if stype != reflect.ValueOf(iv.Elem().Interface()).Type() {
-						stypes += 1
-					}
-				}
-				pv.Index(i).Set(iv.Elem())
-			}
-			if stypes == 1 {
-				sv := reflect.MakeSlice(reflect.SliceOf(stype), len(vals), len(vals))
-				for i := 0; i < len(vals); i++ {
-					sv.Index(i).Set(reflect.ValueOf(pv.Index(i).Interface()))
-				}
-				rv.Set(sv)
-			} else {
-				rv.Set(pv)
-			}
 		}
 		return nil
+	}
+	rv := reflect.ValueOf(obj).Elem()
+	kind := rv.Kind()
+	if c, ok := lookupConverter(rv.Type()); ok && c.unmarshal != nil {
+		kind = reflect.Invalid
+	}
+	switch kind {
+	case reflect.Ptr:
+		if len(vals) == 0 || (len(vals) == 1 && d.skipEmpty(vals[0], rv.Type().Elem())) {
+			return nil
+		}
+		pv := reflect.New(rv.Type().Elem())
+		err := d.fromStrings(vals, pv.Interface())
+		if err != nil {
+			return err
+		}
+		rv.Set(pv)
+		return nil
+	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			break
+		}
+		if d.as != "" {
+			return d.decodeAs(vals, rv)
+		}
+		if len(vals) != 1 {
+			return d.inferSlice(vals, rv)
+		}
+		pv := reflect.New(rv.Type())
+		err := d.fromString(vals[0], pv.Interface())
+		if err != nil {
+			return err
+		}
+		rv.Set(pv.Elem())
+		return nil
 	case reflect.Slice:
+		if isBytes(rv.Type()) {
+			break
+		}
+		vals = d.splitValues(vals)
 		pv := reflect.MakeSlice(rv.Type(), len(vals), len(vals))
 		for i, v := range vals {
+			if err := d.canceled(); err != nil {
+				return err
+			}
 			iv := reflect.New(rv.Type().Elem())
-			err := fromString(v, iv.Interface())
+			err := d.fromString(v, iv.Interface())
 			if err != nil {
 				return err
 			}
@@ -373,18 +897,46 @@ func fromStrings(vals []string, obj interface{}) error {
 		}
 		rv.Set(pv)
 		return nil
+	case reflect.Array:
+		vals = d.splitValues(vals)
+		if len(vals) > rv.Len() {
+			if d.DisallowArrayOverflow {
+				err := fmt.Errorf("too many values for array of length %d", rv.Len())
+				return &FieldError{Value: vals[rv.Len()], Type: rv.Type(), Err: err}
+			}
+			vals = vals[:rv.Len()]
+		}
+		pv := reflect.New(rv.Type()).Elem()
+		for i, v := range vals {
+			err := d.fromString(v, pv.Index(i).Addr().Interface())
+			if err != nil {
+				return err
+			}
+		}
+		rv.Set(pv)
+		return nil
 	case reflect.String:
+		if rv.Type() == numberType {
+			break
+		}
 		if len(vals) == 0 {
 			rv.SetString("")
 		} else if len(vals) == 1 {
 			rv.SetString(vals[0])
 		} else {
-			rv.SetString(strings.Join(vals, ","))
+			switch d.MultiString {
+			case FirstValue:
+				rv.SetString(vals[0])
+			case LastValue:
+				rv.SetString(vals[len(vals)-1])
+			default:
+				rv.SetString(strings.Join(vals, string(separator(d.Separator))))
+			}
 		}
 		return nil
 	}
 	if len(vals) == 0 {
 		return nil
 	}
-	return fromString(vals[len(vals)-1], obj)
+	return d.fromString(vals[len(vals)-1], obj)
 }
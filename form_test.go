@@ -1,8 +1,14 @@
 package form
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -108,3 +114,905 @@ func TestUnmarshal(t *testing.T) {
 	assert.Equal(t, int64(5), ints[0])
 	assert.Equal(t, int64(7), ints[1])
 }
+
+type taggedStruct struct {
+	Username string `json:"username" form:"user"`
+	Password string `json:"password" form:"-"`
+	Email    string `json:"email"`
+	Token    string `json:"-" form:"token"`
+}
+
+func TestFormTag(t *testing.T) {
+	x := &taggedStruct{Username: "john", Password: "secret", Email: "john@example.com", Token: "abc"}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "user=john&email=john%40example.com&token=abc", string(data))
+
+	y := &taggedStruct{}
+	err = UnmarshalForm([]byte("user=paul&password=secret&email=paul%40example.com&token=xyz"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, "paul", y.Username)
+	assert.Equal(t, "", y.Password)
+	assert.Equal(t, "paul@example.com", y.Email)
+	assert.Equal(t, "xyz", y.Token)
+}
+
+type omitStruct struct {
+	Name      string    `json:"name,omitempty"`
+	Age       int       `json:"age,omitempty"`
+	Admin     bool      `json:"admin,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Birthdate time.Time `json:"birth,omitempty"`
+	Score     float64   `json:"score"`
+}
+
+func TestOmitEmpty(t *testing.T) {
+	x := &omitStruct{}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "score=0", string(data))
+
+	x = &omitStruct{
+		Name:      "John",
+		Age:       81,
+		Admin:     true,
+		Tags:      []string{},
+		Birthdate: time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC),
+		Score:     1.5,
+	}
+	data, err = MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&age=81&admin=true&birth=1940-10-09T00%3A00%3A00Z&score=1.5", string(data))
+}
+
+type address struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+	zip    string
+}
+
+type user struct {
+	Name     string   `json:"name"`
+	Address  address  `json:"address"`
+	Billing  *address `json:"billing"`
+	Shipping *address `json:"shipping"`
+}
+
+func TestMarshalNested(t *testing.T) {
+	x := &user{
+		Name:    "John",
+		Address: address{Street: "Main", City: "NYC", zip: "10001"},
+		Billing: &address{Street: "Broadway", City: "NYC"},
+	}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&address%5Bstreet%5D=Main&address%5Bcity%5D=NYC&billing%5Bstreet%5D=Broadway&billing%5Bcity%5D=NYC", string(data))
+}
+
+func TestUnmarshalNested(t *testing.T) {
+	data := []byte("name=John&address%5Bstreet%5D=Main&address%5Bcity%5D=NYC&billing[street]=Broadway&billing[city]=NYC")
+	x := &user{}
+	err := UnmarshalForm(data, x)
+	assert.Nil(t, err)
+	assert.Equal(t, "John", x.Name)
+	assert.Equal(t, "Main", x.Address.Street)
+	assert.Equal(t, "NYC", x.Address.City)
+	assert.NotNil(t, x.Billing)
+	assert.Equal(t, "Broadway", x.Billing.Street)
+	assert.Equal(t, "NYC", x.Billing.City)
+	assert.Nil(t, x.Shipping)
+}
+
+type deepC struct {
+	C int `json:"c"`
+}
+
+type deepB struct {
+	B deepC `json:"b"`
+}
+
+type deepA struct {
+	A *deepB `json:"a"`
+}
+
+func TestUnmarshalDeeplyNested(t *testing.T) {
+	x := &deepA{}
+	err := UnmarshalForm([]byte("a[b][c]=1"), x)
+	assert.Nil(t, err)
+	assert.NotNil(t, x.A)
+	assert.Equal(t, 1, x.A.B.C)
+}
+
+type durationStruct struct {
+	Timeout time.Duration `json:"timeout"`
+}
+
+func TestUnmarshalDuration(t *testing.T) {
+	x := &durationStruct{}
+	err := UnmarshalForm([]byte("timeout=30s"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, x.Timeout)
+
+	err = UnmarshalForm([]byte("timeout=1500"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(1500), x.Timeout)
+
+	err = UnmarshalForm([]byte("timeout=soon"), x)
+	assert.NotNil(t, err)
+}
+
+type layoutStruct struct {
+	Birthdate time.Time  `json:"birth" form:",layout=02/01/2006"`
+	Deadline  *time.Time `form:"deadline,layout=2006-01-02 15:04"`
+	Created   time.Time  `json:"created"`
+}
+
+func TestLayoutTag(t *testing.T) {
+	deadline := time.Date(2024, time.March, 10, 17, 30, 0, 0, time.UTC)
+	x := &layoutStruct{
+		Birthdate: time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC),
+		Deadline:  &deadline,
+		Created:   time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC),
+	}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "birth=09%2F10%2F1940&deadline=2024-03-10+17%3A30&created=2024-01-02T03%3A04%3A05Z", string(data))
+
+	y := &layoutStruct{}
+	err = UnmarshalForm([]byte("birth=09%2F10%2F1940&created=2024-01-02T03%3A04%3A05Z"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, x.Birthdate, y.Birthdate)
+	assert.Equal(t, x.Created, y.Created)
+
+	err = UnmarshalForm([]byte("birth=1940-10-09"), y)
+	assert.NotNil(t, err)
+}
+
+type pointerStruct struct {
+	Name      *string    `json:"name"`
+	Age       *int       `json:"age"`
+	Birthdate *time.Time `json:"birth"`
+}
+
+func TestUnmarshalPointers(t *testing.T) {
+	x := &pointerStruct{}
+	err := UnmarshalForm([]byte("name=John&age=81&birth=1940-10-09T00%3A00%3A00Z"), x)
+	assert.Nil(t, err)
+	assert.NotNil(t, x.Name)
+	assert.Equal(t, "John", *x.Name)
+	assert.NotNil(t, x.Age)
+	assert.Equal(t, 81, *x.Age)
+	assert.NotNil(t, x.Birthdate)
+	assert.Equal(t, time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC), *x.Birthdate)
+
+	y := &pointerStruct{}
+	err = UnmarshalForm([]byte("age=81"), y)
+	assert.Nil(t, err)
+	assert.Nil(t, y.Name)
+	assert.NotNil(t, y.Age)
+	assert.Nil(t, y.Birthdate)
+
+	z := &layoutStruct{}
+	err = UnmarshalForm([]byte("deadline=2024-03-10+17%3A30"), z)
+	assert.Nil(t, err)
+	assert.NotNil(t, z.Deadline)
+	assert.Equal(t, time.Date(2024, time.March, 10, 17, 30, 0, 0, time.UTC), *z.Deadline)
+}
+
+type unparseableStruct struct {
+	Address address  `json:"address"`
+	Events  chan int `json:"events"`
+}
+
+func TestUnmarshalUnparseable(t *testing.T) {
+	x := &unparseableStruct{}
+	err := UnmarshalForm([]byte("events=1"), x)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: field "events" (chan int): can't parse (1) into *chan int (chan)`, err.Error())
+
+	err = UnmarshalForm([]byte("address=Main"), x)
+	assert.NotNil(t, err)
+}
+
+type bytesStruct struct {
+	Data []byte `json:"data"`
+}
+
+func TestBytes(t *testing.T) {
+	x := &bytesStruct{Data: []byte{0x01, 0x02, 0xff}}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "data=AQL%2F", string(data))
+
+	y := &bytesStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x.Data, y.Data)
+
+	err = UnmarshalForm([]byte("data=%21%21"), y)
+	assert.NotNil(t, err)
+
+	enc := NewEncoder()
+	enc.RawBytes = true
+	data, err = enc.Encode(&bytesStruct{Data: []byte("hello world")})
+	assert.Nil(t, err)
+	assert.Equal(t, "data=hello+world", string(data))
+
+	dec := NewDecoder()
+	dec.RawBytes = true
+	err = dec.Decode(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello world"), y.Data)
+}
+
+type customID struct {
+	n int
+}
+
+func (id *customID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("id-%d", id.n)), nil
+}
+
+type customIDStruct struct {
+	IDs   []customID `json:"ids"`
+	Owner customID   `json:"owner"`
+}
+
+func TestMarshalPointerTextMarshaler(t *testing.T) {
+	x := &customIDStruct{IDs: []customID{{1}, {2}}, Owner: customID{3}}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "ids=id-1&ids=id-2&owner=id-3", string(data))
+}
+
+type binaryID struct {
+	hi, lo uint8
+}
+
+func (id binaryID) MarshalBinary() ([]byte, error) {
+	return []byte{id.hi, id.lo}, nil
+}
+
+func (id *binaryID) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return errors.New("binaryID: wrong length")
+	}
+	id.hi, id.lo = b[0], b[1]
+	return nil
+}
+
+type binaryStruct struct {
+	ID    binaryID   `json:"id"`
+	IDs   []binaryID `json:"ids"`
+	Birth time.Time  `json:"birth"`
+}
+
+func TestBinaryMarshaler(t *testing.T) {
+	x := &binaryStruct{
+		ID:    binaryID{1, 2},
+		IDs:   []binaryID{{3, 4}, {255, 255}},
+		Birth: time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC),
+	}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "id=AQI%3D&ids=AwQ%3D&ids=%2F%2F8%3D&birth=1940-10-09T00%3A00%3A00Z", string(data))
+
+	y := &binaryStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	err = UnmarshalForm([]byte("id=AQID"), y)
+	assert.NotNil(t, err)
+	err = UnmarshalForm([]byte("id=%21%21"), y)
+	assert.NotNil(t, err)
+}
+
+type ptrBinaryID uint16
+
+func (id *ptrBinaryID) MarshalBinary() ([]byte, error) {
+	return []byte{byte(*id >> 8), byte(*id)}, nil
+}
+
+func TestPointerBinaryMarshaler(t *testing.T) {
+	data, err := MarshalForm(map[string]ptrBinaryID{"a": 0x0102})
+	assert.Nil(t, err)
+	assert.Equal(t, "a=AQI%3D", string(data))
+	data, err = MarshalForm(map[ptrBinaryID]int{0x0304: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "AwQ%3D=1", string(data))
+}
+
+type embedBase struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Note    string
+}
+
+type embedUser struct {
+	embedBase
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type embedAdmin struct {
+	*embedUser `json:"-"`
+	embedUserPtr
+	Level int `json:"level"`
+}
+
+type embedUserPtr struct {
+	*EmbedOwner
+}
+
+type EmbedOwner struct {
+	Owner string `json:"owner"`
+	Note  string
+}
+
+type embedConflict struct {
+	EmbedOwner
+	embedBase
+	Level int `json:"level"`
+}
+
+func TestEmbeddedFields(t *testing.T) {
+	x := &embedUser{embedBase: embedBase{ID: 1, Name: "base", Created: "today"}, Name: "John", Email: "john@example.com"}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "id=1&created=today&note=&name=John&email=john%40example.com", string(data))
+
+	y := &embedUser{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, y.ID)
+	assert.Equal(t, "today", y.Created)
+	assert.Equal(t, "John", y.Name)
+	assert.Equal(t, "", y.embedBase.Name)
+
+	a := &embedAdmin{Level: 3}
+	data, err = MarshalForm(a)
+	assert.Nil(t, err)
+	assert.Equal(t, "level=3", string(data))
+
+	err = UnmarshalForm([]byte("owner=paul&note=hi&level=2"), a)
+	assert.Nil(t, err)
+	assert.NotNil(t, a.EmbedOwner)
+	assert.Equal(t, "paul", a.Owner)
+	assert.Equal(t, "hi", a.EmbedOwner.Note)
+	assert.Equal(t, 2, a.Level)
+	assert.Nil(t, a.embedUser)
+
+	c := &embedConflict{}
+	c.EmbedOwner.Note = "a"
+	c.embedBase.Note = "b"
+	data, err = MarshalForm(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "owner=&id=0&name=&created=&level=0", string(data))
+}
+
+type arrayStruct struct {
+	Numbers [3]int `json:"numbers"`
+}
+
+func TestArrays(t *testing.T) {
+	x := &arrayStruct{Numbers: [3]int{5, 7, 9}}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "numbers=5&numbers=7&numbers=9", string(data))
+
+	y := &arrayStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, [3]int{5, 7, 9}, y.Numbers)
+
+	err = UnmarshalForm([]byte("numbers=1&numbers=2"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, [3]int{1, 2, 0}, y.Numbers)
+
+	err = UnmarshalForm([]byte("numbers=1&numbers=2&numbers=3&numbers=4"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, [3]int{1, 2, 3}, y.Numbers)
+
+	dec := NewDecoder()
+	dec.DisallowArrayOverflow = true
+	err = dec.Decode([]byte("numbers=1&numbers=2&numbers=3&numbers=4"), y)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: field "numbers" ([3]int): too many values for array of length 3`, err.Error())
+}
+
+type complexStruct struct {
+	Impedance complex128 `json:"z"`
+	Gain      complex64  `json:"gain"`
+}
+
+func TestComplex(t *testing.T) {
+	x := &complexStruct{Impedance: complex(1.5, -2), Gain: complex(0, 0.25)}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "z=%281.5-2i%29&gain=%280%2B0.25i%29", string(data))
+
+	y := &complexStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	err = UnmarshalForm([]byte("z=1+2j"), y)
+	assert.NotNil(t, err)
+}
+
+type smallIntStruct struct {
+	Level int8    `json:"level"`
+	Port  uint16  `json:"port"`
+	Small uint8   `json:"small"`
+	Ratio float32 `json:"ratio"`
+}
+
+func TestUnmarshalOverflow(t *testing.T) {
+	x := &smallIntStruct{}
+	err := UnmarshalForm([]byte("level=-128&port=65535&small=255&ratio=1.5"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &smallIntStruct{Level: -128, Port: 65535, Small: 255, Ratio: 1.5}, x)
+
+	err = UnmarshalForm([]byte("small=300"), x)
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, strconv.ErrRange), "err wraps strconv.ErrRange")
+
+	err = UnmarshalForm([]byte("level=128"), x)
+	assert.NotNil(t, err)
+
+	err = UnmarshalForm([]byte("port=70000"), x)
+	assert.NotNil(t, err)
+
+	err = UnmarshalForm([]byte("ratio=1e39"), x)
+	assert.NotNil(t, err)
+}
+
+type color int
+
+const (
+	red color = iota
+	green
+)
+
+func (c color) MarshalText() ([]byte, error) {
+	switch c {
+	case red:
+		return []byte("red"), nil
+	case green:
+		return []byte("green"), nil
+	}
+	return nil, fmt.Errorf("unknown color %d", int(c))
+}
+
+func (c *color) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "red":
+		*c = red
+	case "green":
+		*c = green
+	default:
+		return fmt.Errorf("unknown color %q", string(text))
+	}
+	return nil
+}
+
+type colorStruct struct {
+	Color   color   `json:"color"`
+	Palette []color `json:"palette"`
+	Bad     color   `json:"bad"`
+}
+
+func TestTextMarshalerNamedInt(t *testing.T) {
+	x := &colorStruct{Color: red, Palette: []color{green, red}, Bad: color(7)}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "color=red&palette=green&palette=red&bad=7", string(data))
+
+	y := &colorStruct{}
+	err = UnmarshalForm([]byte("color=green&palette=red"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, green, y.Color)
+	assert.Equal(t, []color{red}, y.Palette)
+}
+
+func TestMarshalFormValues(t *testing.T) {
+	x := &testStruct{
+		Name:            []string{"John", "Lennon"},
+		Age:             81.8,
+		FavoriteNumbers: []int{5, 7},
+	}
+	values, err := MarshalFormValues(x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"John", "Lennon"}, values["name"])
+	assert.Equal(t, "81.8", values.Get("age"))
+	assert.Equal(t, []string{"5", "7"}, values["numbers"])
+	assert.Equal(t, "0001-01-01T00:00:00Z", values.Get("birth"))
+	values.Set("csrf", "token")
+	assert.Equal(t, "token", values.Get("csrf"))
+
+	values, err = MarshalFormValues(map[string]int{"a": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{"a": {"1"}}, values)
+
+	values, err = MarshalFormValues(&fm{Name: "John"})
+	assert.Nil(t, err)
+	assert.Equal(t, "John", values.Get("n"))
+}
+
+func TestMarshalToValues(t *testing.T) {
+	dst := url.Values{"name": {"Paul"}, "csrf": {"token"}}
+	x := &testStruct{Name: []string{"John"}, Age: 81.8}
+	err := MarshalToValues(x, dst)
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{
+		"name":  {"Paul", "John"},
+		"csrf":  {"token"},
+		"age":   {"81.8"},
+		"birth": {"0001-01-01T00:00:00Z"},
+	}, dst)
+
+	err = MarshalToValues(&fm{Name: "John"}, dst)
+	assert.Nil(t, err)
+	assert.Equal(t, "John", dst.Get("n"))
+
+	err = MarshalToValues(map[string]point2{"p": {}}, dst)
+	assert.NotNil(t, err)
+}
+
+type stringOptionStruct struct {
+	ID    int64   `json:"id,string"`
+	Score float64 `json:"score,string,omitempty"`
+	Admin bool    `json:",string"`
+}
+
+func TestStringOption(t *testing.T) {
+	x := &stringOptionStruct{ID: 12345, Admin: true}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "id=12345&admin=true", string(data))
+
+	y := &stringOptionStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	y = &stringOptionStruct{}
+	err = UnmarshalForm([]byte(`id="12345"&score="1.5"&Admin="true"`), y)
+	assert.Nil(t, err)
+	assert.Equal(t, &stringOptionStruct{ID: 12345, Score: 1.5, Admin: true}, y)
+
+	err = UnmarshalForm([]byte(`id="12345`), y)
+	assert.NotNil(t, err)
+}
+
+type pointerSliceStruct struct {
+	Tags    *[]string    `json:"tags"`
+	Numbers []*int       `json:"numbers"`
+	Dates   []*time.Time `json:"dates"`
+}
+
+func TestUnmarshalPointerSlices(t *testing.T) {
+	x := &pointerSliceStruct{}
+	err := UnmarshalForm([]byte("tags=a&tags=b&numbers=5&numbers=7&dates=1940-10-09T00%3A00%3A00Z"), x)
+	assert.Nil(t, err)
+	assert.NotNil(t, x.Tags)
+	assert.Equal(t, []string{"a", "b"}, *x.Tags)
+	assert.Equal(t, 2, len(x.Numbers))
+	assert.Equal(t, 5, *x.Numbers[0])
+	assert.Equal(t, 7, *x.Numbers[1])
+	assert.Equal(t, 1, len(x.Dates))
+
+	y := &pointerSliceStruct{}
+	err = UnmarshalForm([]byte("numbers=5"), y)
+	assert.Nil(t, err)
+	assert.Nil(t, y.Tags)
+
+	err = UnmarshalForm([]byte("numbers=5&numbers=x"), y)
+	assert.NotNil(t, err)
+}
+
+type numberStruct struct {
+	Amount json.Number `json:"amount"`
+}
+
+func TestJSONNumber(t *testing.T) {
+	x := &numberStruct{Amount: json.Number("12.50")}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "amount=12.50", string(data))
+
+	y := &numberStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	for _, valid := range []string{"0", "-1", "1e10", "1.5E-3", "123456789012345678901234567890"} {
+		err = UnmarshalForm([]byte("amount="+url.QueryEscape(valid)), y)
+		assert.Nil(t, err, valid)
+		assert.Equal(t, json.Number(valid), y.Amount)
+	}
+	for _, invalid := range []string{"abc", "", "01", "1.", ".5", "1e", "NaN", "Inf", "0x10", "1_000"} {
+		err = UnmarshalForm([]byte("amount="+url.QueryEscape(invalid)), y)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+type nullStruct struct {
+	Name  sql.NullString  `json:"name"`
+	Age   sql.NullInt64   `json:"age"`
+	Score sql.NullFloat64 `json:"score"`
+	Admin sql.NullBool    `json:"admin"`
+	Birth sql.NullTime    `json:"birth"`
+}
+
+func TestSQLNullTypes(t *testing.T) {
+	x := &nullStruct{}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(data))
+
+	birth := time.Date(1940, time.October, 9, 0, 0, 0, 0, time.UTC)
+	x = &nullStruct{
+		Name:  sql.NullString{String: "John", Valid: true},
+		Age:   sql.NullInt64{Int64: 81, Valid: true},
+		Score: sql.NullFloat64{Float64: 1.5, Valid: true},
+		Admin: sql.NullBool{Bool: false, Valid: true},
+		Birth: sql.NullTime{Time: birth, Valid: true},
+	}
+	data, err = MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "name=John&age=81&score=1.5&admin=false&birth=1940-10-09T00%3A00%3A00Z", string(data))
+
+	y := &nullStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	y = &nullStruct{}
+	err = UnmarshalForm([]byte("name=&age="), y)
+	assert.Nil(t, err)
+	assert.Equal(t, sql.NullString{Valid: true}, y.Name)
+	assert.Equal(t, sql.NullInt64{}, y.Age)
+	assert.False(t, y.Birth.Valid)
+
+	err = UnmarshalForm([]byte("age=x"), y)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, "age", fe.Key)
+}
+
+type baseStruct struct {
+	Int  int   `json:"int"`
+	Uint uint8 `json:"uint"`
+	Code int   `json:"code,decimal"`
+}
+
+func TestIntegerBases(t *testing.T) {
+	x := &baseStruct{}
+	err := UnmarshalForm([]byte("int=0xff&uint=0b1010&code=1000"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &baseStruct{Int: 255, Uint: 10, Code: 1000}, x)
+
+	for val, expect := range map[string]int{"1_000": 1000, "-0x10": -16, "0o17": 15, "010": 10, "0": 0, "-012": -12} {
+		err = UnmarshalForm([]byte("int="+url.QueryEscape(val)), x)
+		assert.Nil(t, err, val)
+		assert.Equal(t, expect, x.Int, val)
+	}
+
+	err = UnmarshalForm([]byte("code=0xff"), x)
+	assert.NotNil(t, err)
+	err = UnmarshalForm([]byte("uint=0x100"), x)
+	assert.NotNil(t, err)
+
+	dec := NewDecoder()
+	dec.DecimalOnly = true
+	err = dec.Decode([]byte("int=1_000"), x)
+	assert.NotNil(t, err)
+}
+
+func TestDecimalComma(t *testing.T) {
+	type prices struct {
+		Price float64     `form:"price"`
+		Small float32     `form:"small"`
+		Total json.Number `form:"total"`
+		Count int         `form:"count"`
+	}
+	dec := NewDecoder()
+	dec.DecimalComma = true
+	x := &prices{}
+	err := dec.Decode([]byte("price=1.234,56&small=0,5&total=1.000.000,25&count=12"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &prices{Price: 1234.56, Small: 0.5, Total: "1000000.25", Count: 12}, x)
+
+	err = dec.Decode([]byte("price=1,5e3"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, 1500.0, x.Price)
+
+	err = dec.Decode([]byte("total=1,2,3"), x)
+	assert.NotNil(t, err)
+	x = &prices{}
+	err = dec.Decode([]byte("price=1.5"), x)
+	assert.NotNil(t, err)
+	err = dec.Decode([]byte("total=1.5"), x)
+	assert.NotNil(t, err)
+	err = dec.Decode([]byte("price=12.34.567,8"), x)
+	assert.NotNil(t, err)
+	assert.Equal(t, &prices{}, x)
+	err = dec.Decode([]byte("price=-1.500&total=12.345.678"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, &prices{Price: -1500, Total: "12345678"}, x)
+
+	x = &prices{}
+	err = UnmarshalForm([]byte("price=1.5e3"), x)
+	assert.Nil(t, err)
+	assert.Equal(t, 1500.0, x.Price)
+	err = UnmarshalForm([]byte("price=1.234,56"), x)
+	assert.NotNil(t, err)
+}
+
+func TestTopLevelSlices(t *testing.T) {
+	enc := NewEncoder()
+	enc.Unescaped = "[]"
+	data, err := enc.Encode([]string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, "[]=a&[]=b", string(data))
+	var ss []string
+	err = UnmarshalForm(data, &ss)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, ss)
+
+	addrs := []*address{{Street: "Main"}, {City: "Springfield"}}
+	data, err = enc.Encode(addrs)
+	assert.Nil(t, err)
+	assert.Equal(t, "[0][street]=Main&[0][city]=&[1][street]=&[1][city]=Springfield", string(data))
+	var as []address
+	err = UnmarshalForm(data, &as)
+	assert.Nil(t, err)
+	assert.Equal(t, []address{{Street: "Main"}, {City: "Springfield"}}, as)
+
+	data, err = MarshalForm([2]int{4, 5})
+	assert.Nil(t, err)
+	var arr [2]int
+	err = UnmarshalForm(data, &arr)
+	assert.Nil(t, err)
+	assert.Equal(t, [2]int{4, 5}, arr)
+
+	dec := NewDecoder()
+	dec.DisallowUnknownFields = true
+	err = dec.Decode([]byte("[0]=1&x=2"), &arr)
+	assert.Equal(t, &UnknownFieldsError{Keys: []string{"x"}}, err)
+
+	err = UnmarshalForm([]byte("[]=x"), &arr)
+	assert.NotNil(t, err)
+}
+
+type unixStruct struct {
+	Created time.Time `form:"created,unix"`
+	Updated time.Time `form:"updated,unixmilli"`
+	Deleted time.Time `form:"deleted"`
+}
+
+func TestUnixTime(t *testing.T) {
+	x := &unixStruct{
+		Created: time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC),
+		Updated: time.Date(2021, time.March, 4, 5, 6, 7, 890000000, time.UTC),
+		Deleted: time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC),
+	}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "created=1614834367&updated=1614834367890&deleted=2021-03-04T05%3A06%3A07Z", string(data))
+	y := &unixStruct{}
+	err = UnmarshalForm(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	enc := NewEncoder()
+	enc.UnixTime = UnixSeconds
+	data, err = enc.Encode(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "created=1614834367&updated=1614834367890&deleted=1614834367", string(data))
+	dec := NewDecoder()
+	dec.UnixTime = UnixSeconds
+	y = &unixStruct{}
+	err = dec.Decode(data, y)
+	assert.Nil(t, err)
+	assert.Equal(t, x, y)
+
+	err = UnmarshalForm([]byte("created=yesterday"), y)
+	assert.EqualError(t, err, `form: field "created" (time.Time): can't parse (yesterday) as a unix time`)
+}
+
+type verbosity struct {
+	level int
+}
+
+func (v *verbosity) String() string {
+	return strings.Repeat("v", v.level)
+}
+
+func (v *verbosity) Set(s string) error {
+	if strings.Trim(s, "v") != "" {
+		return errors.New("invalid verbosity")
+	}
+	v.level = len(s)
+	return nil
+}
+
+type hostList []string
+
+func (h *hostList) String() string {
+	return strings.Join(*h, ",")
+}
+
+func (h *hostList) Set(s string) error {
+	*h = append(*h, strings.ToLower(s))
+	return nil
+}
+
+type flagStruct struct {
+	Verbosity verbosity  `form:"v"`
+	Quiet     *verbosity `form:"q"`
+	Hosts     hostList   `form:"host"`
+}
+
+func TestFlagValue(t *testing.T) {
+	x := flagStruct{Verbosity: verbosity{3}, Quiet: &verbosity{1}, Hosts: hostList{"a", "b"}}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "v=vvv&q=v&host=a&host=b", string(data))
+	y := &flagStruct{}
+	err = UnmarshalForm([]byte("v=vvv&q=v&host=A&host=b"), y)
+	assert.Nil(t, err)
+	assert.Equal(t, &x, y)
+
+	err = UnmarshalForm([]byte("v=x"), y)
+	assert.EqualError(t, err, `form: field "v" (form.verbosity): invalid verbosity`)
+}
+
+type itemID int
+
+type itemIDs []itemID
+
+type itemPair [2]itemID
+
+type namedSliceStruct struct {
+	IDs    itemIDs  `form:"id"`
+	More   *itemIDs `form:"more"`
+	Joined itemIDs  `form:"joined,split=comma"`
+	Pair   itemPair `form:"pair"`
+	Index  itemIDs  `form:"index"`
+}
+
+func TestNamedSliceTypes(t *testing.T) {
+	x := namedSliceStruct{
+		IDs:    itemIDs{1, 2},
+		More:   &itemIDs{3},
+		Joined: itemIDs{4, 5},
+		Pair:   itemPair{6, 7},
+	}
+	data, err := MarshalForm(x)
+	assert.Nil(t, err)
+	assert.Equal(t, "id=1&id=2&more=3&joined=4%2C5&pair=6&pair=7", string(data))
+	y := &namedSliceStruct{}
+	err = UnmarshalForm(append(data, "&index[1]=9&index[0]=8"...), y)
+	assert.Nil(t, err)
+	x.Index = itemIDs{8, 9}
+	assert.Equal(t, &x, y)
+
+	var ids itemIDs
+	err = UnmarshalForm([]byte("[]=1&[]=2"), &ids)
+	assert.Nil(t, err)
+	assert.Equal(t, itemIDs{1, 2}, ids)
+
+	m := map[string]itemIDs{}
+	err = UnmarshalForm([]byte("a=1&a=2"), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]itemIDs{"a": {1, 2}}, m)
+
+	err = UnmarshalForm([]byte("id=1&id=x"), y)
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe), "err is a *FieldError")
+	assert.Equal(t, reflect.TypeOf(itemID(0)), fe.Type)
+}
@@ -0,0 +1,79 @@
+package form
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// Precedence selects how DecodeRequest handles keys that appear in both
+// the URL query and the request body.
+type Precedence int
+
+const (
+	PreferBody  Precedence = iota // use the body's values
+	PreferQuery                   // use the query's values
+	MergeValues                   // body values followed by query values, as in http.Request.Form
+)
+
+func (p Precedence) merge(body, query url.Values) url.Values {
+	values := url.Values{}
+	for k, v := range body {
+		values[k] = v
+	}
+	for k, v := range query {
+		if _, ok := values[k]; !ok || p == PreferQuery {
+			values[k] = v
+		} else if p == MergeValues {
+			values[k] = append(append([]string{}, values[k]...), v...)
+		}
+	}
+	return values
+}
+
+// DecodeRequest decodes the form data in an HTTP request into obj, using
+// a default Decoder.
+func DecodeRequest(r *http.Request, obj interface{}) error {
+	return NewDecoder().DecodeRequest(r, obj)
+}
+
+// DecodeRequest decodes the form data in an HTTP request into obj.  That
+// is the URL query, combined according to Precedence with the body of
+// POST, PUT and PATCH requests.  The body must have the
+// application/x-www-form-urlencoded content type, and is read subject to
+// MaxBytes.
+func (d *Decoder) DecodeRequest(r *http.Request, obj interface{}) error {
+	query, err := parseQuery([]byte(r.URL.RawQuery))
+	if err != nil {
+		return err
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return d.DecodeValues(query, obj)
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" && (r.Body == nil || r.Body == http.NoBody) {
+		return d.DecodeValues(query, obj)
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return fmt.Errorf("form: invalid content type %q: %w", ct, err)
+	}
+	if mt != "application/x-www-form-urlencoded" {
+		return fmt.Errorf("form: unsupported content type %q", mt)
+	}
+	var data []byte
+	if r.Body != nil {
+		data, err = d.readAll(r.Body)
+		if err != nil {
+			return err
+		}
+	}
+	body, err := parseQuery(data)
+	if err != nil {
+		return err
+	}
+	return d.decodeValues(d.Precedence.merge(body, query), data, obj)
+}
@@ -0,0 +1,96 @@
+package form
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?name=John&age=81.8", nil)
+	x := &testStruct{}
+	err := DecodeRequest(r, x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"John"}, x.Name)
+	assert.Equal(t, 81.8, x.Age)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name=Paul&age=80"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	x = &testStruct{}
+	err = DecodeRequest(r, x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Paul"}, x.Name)
+	assert.Equal(t, 80.0, x.Age)
+
+	r = httptest.NewRequest(http.MethodPost, "/?name=John", nil)
+	x = &testStruct{}
+	err = DecodeRequest(r, x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"John"}, x.Name)
+
+	r, err = http.NewRequest(http.MethodPost, "/?name=John", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	x = &testStruct{}
+	err = DecodeRequest(r, x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"John"}, x.Name)
+
+	r = httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"name":"Paul"}`))
+	r.Header.Set("Content-Type", "application/json")
+	err = DecodeRequest(r, x)
+	assert.NotNil(t, err)
+	assert.Equal(t, `form: unsupported content type "application/json"`, err.Error())
+
+	r = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader("name=Paul"))
+	r.Header.Set("Content-Type", "bogus/")
+	err = DecodeRequest(r, x)
+	assert.NotNil(t, err)
+
+	dec := NewDecoder()
+	dec.MaxBytes = 4
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name=Paul"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err = dec.DecodeRequest(r, x)
+	assert.Equal(t, ErrTooLarge, err)
+}
+
+func TestDecodeRequestPrecedence(t *testing.T) {
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/?name=John&age=81.8", strings.NewReader("name=Paul&numbers=5"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	dec := NewDecoder()
+	x := &testStruct{}
+	err := dec.DecodeRequest(newRequest(), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Paul"}, x.Name)
+	assert.Equal(t, 81.8, x.Age)
+	assert.Equal(t, []int{5}, x.FavoriteNumbers)
+
+	dec.Precedence = PreferQuery
+	x = &testStruct{}
+	err = dec.DecodeRequest(newRequest(), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"John"}, x.Name)
+	assert.Equal(t, 81.8, x.Age)
+	assert.Equal(t, []int{5}, x.FavoriteNumbers)
+
+	dec.Precedence = MergeValues
+	x = &testStruct{}
+	err = dec.DecodeRequest(newRequest(), x)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Paul", "John"}, x.Name)
+
+	r := newRequest()
+	assert.Nil(t, r.ParseForm())
+	v := url.Values{}
+	err = dec.DecodeRequest(newRequest(), &v)
+	assert.Nil(t, err)
+	assert.Equal(t, r.Form, v)
+}
@@ -0,0 +1,137 @@
+package form
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// OrderedValues is like url.Values but remembers the order in which
+// values were added, and encodes them in that order rather than sorting
+// by key.  This is useful when the exact encoded form matters, such as
+// when signing a request.  The zero value is ready to use.
+type OrderedValues struct {
+	pairs []pair
+}
+
+// Get returns the first value for key, or "" if there is none.
+func (v *OrderedValues) Get(key string) string {
+	for _, p := range v.pairs {
+		if p.key == key {
+			return p.value
+		}
+	}
+	return ""
+}
+
+// Has reports whether key has any values.
+func (v *OrderedValues) Has(key string) bool {
+	for _, p := range v.pairs {
+		if p.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Add appends value to key.
+func (v *OrderedValues) Add(key, value string) {
+	v.pairs = append(v.pairs, pair{key, value})
+}
+
+// Set replaces any values for key with value.  If key already had
+// values, the new value takes the position of the first of them.
+func (v *OrderedValues) Set(key, value string) {
+	pairs := v.pairs[:0]
+	found := false
+	for _, p := range v.pairs {
+		if p.key != key {
+			pairs = append(pairs, p)
+		} else if !found {
+			pairs = append(pairs, pair{key, value})
+			found = true
+		}
+	}
+	if !found {
+		pairs = append(pairs, pair{key, value})
+	}
+	v.pairs = pairs
+}
+
+// Del removes all values for key.
+func (v *OrderedValues) Del(key string) {
+	pairs := v.pairs[:0]
+	for _, p := range v.pairs {
+		if p.key != key {
+			pairs = append(pairs, p)
+		}
+	}
+	v.pairs = pairs
+}
+
+// Keys returns each distinct key in the order it was first added.
+func (v *OrderedValues) Keys() []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, p := range v.pairs {
+		if !seen[p.key] {
+			seen[p.key] = true
+			keys = append(keys, p.key)
+		}
+	}
+	return keys
+}
+
+// Values returns the values as an unordered url.Values.
+func (v *OrderedValues) Values() url.Values {
+	values := url.Values{}
+	for _, p := range v.pairs {
+		values.Add(p.key, p.value)
+	}
+	return values
+}
+
+// Encode encodes the values in the order they were added.
+func (v OrderedValues) Encode() string {
+	return encodePairs(v.pairs, url.QueryEscape, url.QueryEscape)
+}
+
+func (v OrderedValues) MarshalForm() ([]byte, error) {
+	return []byte(v.Encode()), nil
+}
+
+func (v *OrderedValues) UnmarshalForm(data []byte) error {
+	pairs, err := parseOrdered(string(data))
+	if err != nil {
+		return invalidQuery(err)
+	}
+	v.pairs = pairs
+	return nil
+}
+
+// parseOrdered parses a query string the same way as url.ParseQuery but
+// keeps the pairs in their original order.
+func parseOrdered(query string) ([]pair, error) {
+	pairs := []pair{}
+	for query != "" {
+		var part string
+		part, query, _ = strings.Cut(query, "&")
+		if strings.Contains(part, ";") {
+			return nil, errors.New("invalid semicolon separator in query")
+		}
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair{key, value})
+	}
+	return pairs, nil
+}
@@ -0,0 +1,45 @@
+package form
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderedValues(t *testing.T) {
+	v := &OrderedValues{}
+	v.Add("oauth_nonce", "abc")
+	v.Add("b", "2")
+	v.Add("a", "1")
+	v.Add("b", "3")
+	assert.Equal(t, "oauth_nonce=abc&b=2&a=1&b=3", v.Encode())
+	assert.Equal(t, []string{"oauth_nonce", "b", "a"}, v.Keys())
+	assert.Equal(t, "2", v.Get("b"))
+	assert.True(t, v.Has("a"))
+	assert.False(t, v.Has("c"))
+	assert.Equal(t, url.Values{"oauth_nonce": {"abc"}, "a": {"1"}, "b": {"2", "3"}}, v.Values())
+
+	data, err := MarshalForm(v)
+	assert.Nil(t, err)
+	assert.Equal(t, "oauth_nonce=abc&b=2&a=1&b=3", string(data))
+
+	data, err = MarshalForm(*v)
+	assert.Nil(t, err)
+	assert.Equal(t, "oauth_nonce=abc&b=2&a=1&b=3", string(data))
+
+	v.Set("b", "4")
+	v.Del("oauth_nonce")
+	v.Set("c", "5 6")
+	assert.Equal(t, "b=4&a=1&c=5+6", v.Encode())
+
+	w := &OrderedValues{}
+	err = UnmarshalForm([]byte("z=1&y=%202&&z=3&x"), w)
+	assert.Nil(t, err)
+	assert.Equal(t, "z=1&y=+2&z=3&x=", w.Encode())
+
+	err = UnmarshalForm([]byte("a=1;b=2"), w)
+	assert.NotNil(t, err)
+	err = UnmarshalForm([]byte("a=%zz"), w)
+	assert.NotNil(t, err)
+}